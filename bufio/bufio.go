@@ -2,14 +2,32 @@ package bufio
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 
 	"github.com/nexuer/utils/unsafe"
 )
 
-// ReadLineFunc read the io.Reader line by line and call f(c) to process each line of string
-func ReadLineFunc(reader io.Reader, f func(num int, line string) error) error {
+// ReadLineOptions configures ReadLineFunc and ReadLineBytesFunc. The zero
+// value limits lines to bufio.MaxScanTokenSize bytes.
+type ReadLineOptions struct {
+	// MaxLineSize is the size of the longest line that can be read,
+	// including its line ending. A longer line makes reading fail with
+	// bufio.ErrTooLong.
+	MaxLineSize int
+}
+
+func newLineScanner(reader io.Reader, opts []ReadLineOptions) *bufio.Scanner {
 	scanner := bufio.NewScanner(reader)
+	if len(opts) > 0 && opts[0].MaxLineSize > 0 {
+		scanner.Buffer(nil, opts[0].MaxLineSize)
+	}
+	return scanner
+}
+
+// ReadLineFunc read the io.Reader line by line and call f(c) to process each line of string
+func ReadLineFunc(reader io.Reader, f func(num int, line string) error, opts ...ReadLineOptions) error {
+	scanner := newLineScanner(reader, opts)
 	num := 0
 	for scanner.Scan() {
 		num++
@@ -21,8 +39,8 @@ func ReadLineFunc(reader io.Reader, f func(num int, line string) error) error {
 }
 
 // ReadLineBytesFunc read the io.Reader line by line and call f(c) to process each line of bytes
-func ReadLineBytesFunc(reader io.Reader, f func(num int, line []byte) error) error {
-	scanner := bufio.NewScanner(reader)
+func ReadLineBytesFunc(reader io.Reader, f func(num int, line []byte) error, opts ...ReadLineOptions) error {
+	scanner := newLineScanner(reader, opts)
 	num := 0
 	for scanner.Scan() {
 		num++
@@ -32,3 +50,95 @@ func ReadLineBytesFunc(reader io.Reader, f func(num int, line []byte) error) err
 	}
 	return scanner.Err()
 }
+
+// reverseBlockSize is how much ReverseLineFunc reads at a time.
+const reverseBlockSize = 32 * 1024
+
+// ReverseLineFunc reads the first size bytes of r backwards and calls f for
+// each line of string, starting with the last one. Lines are numbered from
+// the end, so the last line is 1. As with ReadLineFunc, line endings are
+// removed, a final line without a newline is still reported and the line is
+// only valid until f returns.
+func ReverseLineFunc(r io.ReaderAt, size int64, f func(num int, line string) error) error {
+	if size <= 0 {
+		return nil
+	}
+	buf := make([]byte, min(size, reverseBlockSize))
+	num := 0
+	emit := func(line []byte) error {
+		num++
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+		return f(num, unsafe.BytesToString(line))
+	}
+
+	// carry holds the end of a line that started in an earlier block.
+	var carry []byte
+	pos := size
+	for pos > 0 {
+		n := min(pos, int64(len(buf)))
+		pos -= n
+		block := buf[:n]
+		if m, err := r.ReadAt(block, pos); m < len(block) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if pos+n == size && block[n-1] == '\n' {
+			// A trailing newline ends the last line rather than starting a
+			// new, empty one.
+			block = block[:n-1]
+		}
+		for {
+			i := bytes.LastIndexByte(block, '\n')
+			if i < 0 {
+				carry = append(bytes.Clone(block), carry...)
+				break
+			}
+			line := block[i+1:]
+			if len(carry) > 0 {
+				line = append(line[:len(line):len(line)], carry...)
+				carry = carry[:0]
+			}
+			if err := emit(line); err != nil {
+				return err
+			}
+			block = block[:i]
+		}
+	}
+	return emit(carry)
+}
+
+// ScanLineFunc splits data into lines and calls f for each line of string,
+// like ReadLineFunc but without copying and without a limit on the line
+// length. The line shares memory with data, so it must not be kept after
+// data changes, for example after a memory mapping is closed.
+func ScanLineFunc(data []byte, f func(num int, line string) error) error {
+	return ScanLineBytesFunc(data, func(num int, line []byte) error {
+		return f(num, unsafe.BytesToString(line))
+	})
+}
+
+// ScanLineBytesFunc splits data into lines and calls f for each line of
+// bytes. The line is a subslice of data.
+func ScanLineBytesFunc(data []byte, f func(num int, line []byte) error) error {
+	num := 0
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+		num++
+		if err := f(num, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
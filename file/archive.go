@@ -0,0 +1,593 @@
+package file
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	errArchiveFormat         = errors.New("unknown archive format")
+	errArchiveTooLarge       = errors.New("archive exceeds the size limit")
+	errArchiveTooManyEntries = errors.New("archive exceeds the entry limit")
+)
+
+const (
+	// DefaultMaxExtractSize is the default limit on the total size of the
+	// files extracted from one archive.
+	DefaultMaxExtractSize = 1 << 30
+	// DefaultMaxExtractEntries is the default limit on the number of
+	// entries in one archive.
+	DefaultMaxExtractEntries = 100000
+)
+
+// ArchiveFormat is the format of an archive file.
+type ArchiveFormat int8
+
+const (
+	// FormatAuto detects the format, from the content when extracting and
+	// from the file name extension when archiving.
+	FormatAuto ArchiveFormat = iota
+	FormatTar
+	FormatTarGz
+	FormatZip
+)
+
+func (f ArchiveFormat) String() string {
+	switch f {
+	case FormatAuto:
+		return "auto"
+	case FormatTar:
+		return "tar"
+	case FormatTarGz:
+		return "tar.gz"
+	case FormatZip:
+		return "zip"
+	}
+	return "unknown"
+}
+
+// ExtractOptions configures Extract. The zero value detects the format and
+// applies the default limits.
+type ExtractOptions struct {
+	Format ArchiveFormat
+
+	// MaxSize limits the total size of the extracted files and MaxEntries
+	// the number of entries read. Zero selects the defaults and a negative
+	// value disables the limit.
+	MaxSize    int64
+	MaxEntries int
+
+	// PreserveTimes keeps the modification times stored in the archive.
+	PreserveTimes bool
+
+	// Include and Exclude filter entries as in CopyDirOptions, with paths
+	// relative to the archive root.
+	Include FilterFunc
+	Exclude FilterFunc
+}
+
+// Extract unpacks a tar, gzipped tar or zip archive into dst, creating dst
+// if needed. Permission bits are preserved, except for setuid, setgid and
+// sticky bits. Symbolic and hard links are recreated if they stay inside
+// dst.
+//
+// Entries whose path, or the links they go through, would escape from dst
+// are rejected with an error, as are archives going over the size or entry
+// limits. Extraction stops at the first error, leaving what was already
+// extracted in place.
+func Extract(archivePath, dst string, opts ...ExtractOptions) error {
+	x := &extractor{dst: filepath.Clean(dst), opts: optional(opts)}
+	if x.opts.MaxSize == 0 {
+		x.opts.MaxSize = DefaultMaxExtractSize
+	}
+	if x.opts.MaxEntries == 0 {
+		x.opts.MaxEntries = DefaultMaxExtractEntries
+	}
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	format := x.opts.Format
+	if format == FormatAuto {
+		if format, err = detectArchiveFormat(f); err != nil {
+			return &fs.PathError{Op: "Extract", Path: archivePath, Err: err}
+		}
+	}
+	if err = os.MkdirAll(x.dst, DefaultFileMode); err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatTar, FormatTarGz:
+		err = x.extractTar(f, format == FormatTarGz)
+	case FormatZip:
+		err = x.extractZip(f)
+	default:
+		err = errArchiveFormat
+	}
+	if err == nil {
+		err = x.finishDirs()
+	}
+	if err != nil {
+		if _, ok := err.(*fs.PathError); !ok {
+			err = &fs.PathError{Op: "Extract", Path: archivePath, Err: err}
+		}
+	}
+	return err
+}
+
+// detectArchiveFormat sniffs the format from the first bytes of f and
+// rewinds it.
+func detectArchiveFormat(f *os.File) (ArchiveFormat, error) {
+	magic := make([]byte, 4)
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	magic = magic[:n]
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	}
+	return FormatTar, nil
+}
+
+type extractor struct {
+	dst     string
+	opts    ExtractOptions
+	size    int64
+	entries int
+
+	// excluded holds excluded directories, whose contents are skipped too.
+	excluded []string
+
+	// dirs holds the extracted directories, whose metadata is applied last
+	// so that read-only directories can still be filled.
+	dirs []extractedDir
+}
+
+type extractedDir struct {
+	path    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// archiveEntry is a tar or zip entry.
+type archiveEntry struct {
+	name     string
+	fi       fs.FileInfo
+	linkname string
+	hardLink bool
+	open     func() (io.ReadCloser, error)
+}
+
+func (x *extractor) extractTar(r io.Reader, gz bool) error {
+	if gz {
+		zr, err := gzip.NewReader(bufio.NewReader(r))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e := archiveEntry{
+			name:     hdr.Name,
+			fi:       hdr.FileInfo(),
+			linkname: hdr.Linkname,
+			hardLink: hdr.Typeflag == tar.TypeLink,
+			open:     func() (io.ReadCloser, error) { return io.NopCloser(tr), nil },
+		}
+		if err = x.extract(e); err != nil {
+			return err
+		}
+	}
+}
+
+func (x *extractor) extractZip(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		e := archiveEntry{name: zf.Name, fi: zf.FileInfo(), open: zf.Open}
+		if e.fi.Mode()&fs.ModeSymlink != 0 {
+			if e.linkname, err = readZipLink(zf); err != nil {
+				return err
+			}
+		}
+		if err = x.extract(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readZipLink returns the target of a symbolic link stored in a zip file,
+// which is kept as the content of the entry.
+func readZipLink(zf *zip.File) (string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, 4096))
+	return string(b), err
+}
+
+func (x *extractor) extract(e archiveEntry) error {
+	if x.entries++; x.opts.MaxEntries > 0 && x.entries > x.opts.MaxEntries {
+		return errArchiveTooManyEntries
+	}
+	rel := path.Clean(e.name)
+	if rel == "." {
+		return nil
+	}
+	if !localName(rel) {
+		return &fs.PathError{Op: "Extract", Path: e.name, Err: errPathEscapes}
+	}
+	for _, dir := range x.excluded {
+		if strings.HasPrefix(rel, dir+"/") {
+			return nil
+		}
+	}
+	d := fs.FileInfoToDirEntry(e.fi)
+	if x.opts.Exclude != nil && x.opts.Exclude(rel, d) {
+		if d.IsDir() {
+			x.excluded = append(x.excluded, rel)
+		}
+		return nil
+	}
+	if !d.IsDir() && x.opts.Include != nil && !x.opts.Include(rel, d) {
+		return nil
+	}
+
+	// Resolve the parent only, so that an existing link in place of the
+	// entry is replaced rather than followed.
+	parent, err := resolveInRoot(x.dst, path.Dir(rel), false)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(parent, DefaultFileMode); err != nil {
+		return err
+	}
+	p := filepath.Join(parent, path.Base(rel))
+
+	mode := e.fi.Mode()
+	switch {
+	case mode.IsDir():
+		if err = os.MkdirAll(p, DefaultFileMode); err != nil {
+			return err
+		}
+		x.dirs = append(x.dirs, extractedDir{path: p, mode: mode.Perm(), modTime: e.fi.ModTime()})
+		return nil
+	case e.hardLink:
+		return x.extractHardLink(p, e.linkname)
+	case mode&fs.ModeSymlink != 0:
+		return x.extractSymlink(p, rel, e.linkname)
+	case mode.IsRegular():
+		if err = x.extractFile(p, e); err != nil {
+			return err
+		}
+	default:
+		// Devices, pipes and sockets are not extracted.
+		return nil
+	}
+	if x.opts.PreserveTimes {
+		return os.Chtimes(p, time.Time{}, e.fi.ModTime())
+	}
+	return nil
+}
+
+// localName reports whether the slash-separated archive name stays below the
+// directory it is extracted to. Backslashes are rejected on every platform:
+// archives use forward slashes, and on Windows a backslash would let a name
+// such as `..\evil` pass as a single element.
+func localName(name string) bool {
+	return !strings.Contains(name, `\`) && filepath.IsLocal(filepath.FromSlash(name))
+}
+
+func (x *extractor) extractFile(p string, e archiveEntry) error {
+	if err := removeNonDir(p); err != nil {
+		return err
+	}
+	rc, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// O_EXCL keeps a link created concurrently from being followed.
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, e.fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	r := io.Reader(rc)
+	if x.opts.MaxSize > 0 {
+		r = io.LimitReader(rc, x.opts.MaxSize-x.size+1)
+	}
+	n, err := io.Copy(f, r)
+	x.size += n
+	if err == nil && x.opts.MaxSize > 0 && x.size > x.opts.MaxSize {
+		err = errArchiveTooLarge
+	}
+	if err == nil {
+		// Apply the exact mode regardless of the umask.
+		err = f.Chmod(e.fi.Mode().Perm())
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (x *extractor) extractSymlink(p, rel, target string) error {
+	escape := &fs.PathError{Op: "Extract", Path: rel, Err: errPathEscapes}
+	if target == "" || isAbsPath(target) || !leadingDotDot(target) {
+		return escape
+	}
+	// Resolve the target through the links already extracted, without
+	// cleaning it first, as the kernel will when the link is followed.
+	if _, err := resolveInRoot(x.dst, path.Dir(rel)+"/"+target, false); err != nil {
+		if errors.Is(err, errPathEscapes) {
+			return escape
+		}
+		return err
+	}
+	if err := removeNonDir(p); err != nil {
+		return err
+	}
+	return os.Symlink(target, p)
+}
+
+// leadingDotDot reports whether ".." only appears at the start of target.
+// A ".." after a name that does not exist yet could leave dst once a later
+// entry turns that name into a link, so such targets are not allowed.
+func leadingDotDot(target string) bool {
+	named := false
+	for _, part := range strings.FieldsFunc(target, func(r rune) bool {
+		return strings.ContainsRune(pathSeparators, r)
+	}) {
+		switch part {
+		case ".":
+		case "..":
+			if named {
+				return false
+			}
+		default:
+			named = true
+		}
+	}
+	return true
+}
+
+func (x *extractor) extractHardLink(p, target string) error {
+	if !localName(path.Clean(target)) {
+		return &fs.PathError{Op: "Extract", Path: target, Err: errPathEscapes}
+	}
+	old, err := resolveInRoot(x.dst, target, false)
+	if err != nil {
+		return err
+	}
+	if err = removeNonDir(p); err != nil {
+		return err
+	}
+	return os.Link(old, p)
+}
+
+func (x *extractor) finishDirs() error {
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		d := x.dirs[i]
+		if err := os.Chmod(d.path, d.mode); err != nil {
+			return err
+		}
+		if x.opts.PreserveTimes {
+			if err := os.Chtimes(d.path, time.Time{}, d.modTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeNonDir removes whatever is at p unless it is a directory.
+func removeNonDir(p string) error {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if fi.IsDir() {
+		return &fs.PathError{Op: "Extract", Path: p, Err: errIsDir}
+	}
+	return os.Remove(p)
+}
+
+// Archive packs the contents of srcDir into a new archive at archivePath,
+// keeping permission bits, modification times and symbolic links. With
+// FormatAuto the format follows the extension of archivePath: .tar,
+// .tar.gz, .tgz or .zip. The archive is written atomically.
+func Archive(srcDir, archivePath string, format ArchiveFormat) (err error) {
+	if format == FormatAuto {
+		format = archiveFormatOf(archivePath)
+	}
+	if format != FormatTar && format != FormatTarGz && format != FormatZip {
+		return &fs.PathError{Op: "Archive", Path: archivePath, Err: errArchiveFormat}
+	}
+	entries, err := Walk(srcDir)
+	if err != nil {
+		return err
+	}
+	w, err := NewAtomicWriter(archivePath, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = w.Abort()
+		}
+	}()
+
+	self, _ := filepath.Abs(archivePath)
+	tmpPrefix := "." + filepath.Base(archivePath) + ".tmp-"
+	var aw archiveWriter
+	switch format {
+	case FormatZip:
+		aw = &zipArchiveWriter{w: zip.NewWriter(w)}
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		aw = &tarArchiveWriter{w: tar.NewWriter(gz), gz: gz}
+	default:
+		aw = &tarArchiveWriter{w: tar.NewWriter(w)}
+	}
+	for _, e := range entries {
+		if abs, _ := filepath.Abs(e.Path); abs == self || strings.HasPrefix(e.Entry.Name(), tmpPrefix) {
+			// Do not pack the archive being written into itself.
+			continue
+		}
+		fi, err := e.Entry.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		switch {
+		case fi.Mode()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(e.Path); err != nil {
+				return err
+			}
+		case !fi.IsDir() && !fi.Mode().IsRegular():
+			continue
+		}
+		if err = aw.add(e.Path, e.Rel, fi, link); err != nil {
+			return err
+		}
+	}
+	if err = aw.close(); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func archiveFormatOf(name string) ArchiveFormat {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz
+	case strings.HasSuffix(name, ".tar"):
+		return FormatTar
+	}
+	return FormatAuto
+}
+
+type archiveWriter interface {
+	add(p, rel string, fi fs.FileInfo, link string) error
+	close() error
+}
+
+type tarArchiveWriter struct {
+	w  *tar.Writer
+	gz *gzip.Writer
+}
+
+func (a *tarArchiveWriter) add(p, rel string, fi fs.FileInfo, link string) error {
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = rel
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+	if err = a.w.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	return copyFileTo(a.w, p)
+}
+
+func (a *tarArchiveWriter) close() error {
+	err := a.w.Close()
+	if a.gz != nil {
+		if gzErr := a.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	return err
+}
+
+type zipArchiveWriter struct {
+	w *zip.Writer
+}
+
+func (a *zipArchiveWriter) add(p, rel string, fi fs.FileInfo, link string) error {
+	hdr, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return err
+	}
+	hdr.Name = rel
+	if fi.IsDir() {
+		hdr.Name += "/"
+	} else if fi.Mode().IsRegular() {
+		hdr.Method = zip.Deflate
+	}
+	w, err := a.w.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	if link != "" {
+		_, err = io.WriteString(w, link)
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	return copyFileTo(w, p)
+}
+
+func (a *zipArchiveWriter) close() error {
+	return a.w.Close()
+}
+
+func copyFileTo(w io.Writer, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
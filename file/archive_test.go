@@ -0,0 +1,252 @@
+package file
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestArchiveExtract(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"a.txt":       "hello",
+		"bin/run.sh":  "#!/bin/sh\n",
+		"sub/c/d.txt": "deep",
+		"skip/x.txt":  "skipped",
+		"notes.log":   "log",
+	})
+	if err := os.Chmod(filepath.Join(src, "bin/run.sh"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	links := runtime.GOOS != "windows"
+	if links {
+		if err := os.Symlink("../a.txt", filepath.Join(src, "sub/link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"out.tar", "out.tar.gz", "out.zip"} {
+		archive := filepath.Join(t.TempDir(), name)
+		if err := Archive(src, archive, FormatAuto); err != nil {
+			t.Fatalf("Archive(%s) error = %v", name, err)
+		}
+		dst := filepath.Join(t.TempDir(), "dst")
+		err := Extract(archive, dst, ExtractOptions{
+			Exclude: func(path string, d fs.DirEntry) bool { return path == "skip" },
+			Include: func(path string, d fs.DirEntry) bool { return !strings.HasSuffix(path, ".log") },
+		})
+		if err != nil {
+			t.Fatalf("Extract(%s) error = %v", name, err)
+		}
+		for p, want := range map[string]string{"a.txt": "hello", "bin/run.sh": "#!/bin/sh\n", "sub/c/d.txt": "deep"} {
+			if b, err := os.ReadFile(filepath.Join(dst, p)); err != nil || string(b) != want {
+				t.Errorf("%s: %s = %q, %v; want %q", name, p, b, err, want)
+			}
+		}
+		for _, p := range []string{"skip", "notes.log"} {
+			if IsExist(filepath.Join(dst, p)) {
+				t.Errorf("%s: %s was extracted despite the filters", name, p)
+			}
+		}
+		if runtime.GOOS != "windows" {
+			if fi, err := os.Stat(filepath.Join(dst, "bin/run.sh")); err != nil || fi.Mode().Perm() != 0o750 {
+				t.Errorf("%s: mode of run.sh = %v, %v; want 0750", name, fi.Mode(), err)
+			}
+		}
+		if links {
+			if target, err := os.Readlink(filepath.Join(dst, "sub/link")); err != nil || target != "../a.txt" {
+				t.Errorf("%s: link target = %q, %v", name, target, err)
+			}
+		}
+	}
+}
+
+func TestArchiveIntoSource(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"a.txt": "a"})
+	archive := filepath.Join(src, "self.tar")
+	if err := Archive(src, archive, FormatAuto); err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	if err := Extract(archive, dst); err != nil {
+		t.Fatal(err)
+	}
+	if IsExist(filepath.Join(dst, "self.tar")) {
+		t.Errorf("the archive contains itself")
+	}
+	if err := Archive(src, filepath.Join(src, "out.rar"), FormatAuto); !errors.Is(err, errArchiveFormat) {
+		t.Errorf("Archive(.rar) error = %v, want %v", err, errArchiveFormat)
+	}
+}
+
+func writeTestTar(t *testing.T, entries []tar.Header, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range entries {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(content))
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0o644
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(content))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "test.tar")
+	if err := os.WriteFile(p, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tar.Header
+		want    error
+
+		// outsideLink creates dst/out as a link to the directory holding
+		// dst before extracting.
+		outsideLink bool
+	}{
+		{
+			name:    "dotdot",
+			entries: []tar.Header{{Name: "../evil", Typeflag: tar.TypeReg}},
+			want:    errPathEscapes,
+		},
+		{
+			name:    "absolute",
+			entries: []tar.Header{{Name: "/tmp/evil", Typeflag: tar.TypeReg}},
+			want:    errPathEscapes,
+		},
+		{
+			name:    "backslash",
+			entries: []tar.Header{{Name: `..\..\evil.exe`, Typeflag: tar.TypeReg}},
+			want:    errPathEscapes,
+		},
+		{
+			name:    "drive letter",
+			entries: []tar.Header{{Name: `C:\x`, Typeflag: tar.TypeReg}},
+			want:    errPathEscapes,
+		},
+		{
+			name:        "through existing symlink",
+			entries:     []tar.Header{{Name: "out/evil", Typeflag: tar.TypeReg}},
+			want:        errPathEscapes,
+			outsideLink: true,
+		},
+		{
+			name:    "escaping symlink",
+			entries: []tar.Header{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+			want:    errPathEscapes,
+		},
+		{
+			name: "symlink chain",
+			entries: []tar.Header{
+				{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0o755},
+				{Name: "sub/y", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				{Name: "x", Typeflag: tar.TypeSymlink, Linkname: "sub/y/.."},
+			},
+			want: errPathEscapes,
+		},
+		{
+			name: "symlink below a link",
+			entries: []tar.Header{
+				{Name: "d/", Typeflag: tar.TypeDir, Mode: 0o755},
+				{Name: "d/up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				{Name: "d/up/z", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+			},
+			want: errPathEscapes,
+		},
+		{
+			name: "symlink through a later link",
+			entries: []tar.Header{
+				{Name: "x", Typeflag: tar.TypeSymlink, Linkname: "later/.."},
+				{Name: "later", Typeflag: tar.TypeSymlink, Linkname: "."},
+			},
+			want: errPathEscapes,
+		},
+		{
+			name:    "escaping hard link",
+			entries: []tar.Header{{Name: "link", Typeflag: tar.TypeLink, Linkname: "../../etc/passwd"}},
+			want:    errPathEscapes,
+		},
+		{
+			name: "too large",
+			entries: []tar.Header{
+				{Name: "a", Typeflag: tar.TypeReg},
+				{Name: "b", Typeflag: tar.TypeReg},
+			},
+			want: errArchiveTooLarge,
+		},
+		{
+			name: "too many entries",
+			entries: []tar.Header{
+				{Name: "a/", Typeflag: tar.TypeDir}, {Name: "b/", Typeflag: tar.TypeDir},
+				{Name: "c/", Typeflag: tar.TypeDir}, {Name: "d/", Typeflag: tar.TypeDir},
+			},
+			want: errArchiveTooManyEntries,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" && strings.Contains(test.name, "symlink") {
+				t.Skip("symbolic links need extra privileges on windows")
+			}
+			archive := writeTestTar(t, test.entries, "0123456789")
+			root := t.TempDir()
+			dst := filepath.Join(root, "dst")
+			if test.outsideLink {
+				if err := os.Mkdir(dst, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(root, filepath.Join(dst, "out")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := Extract(archive, dst, ExtractOptions{MaxSize: 15, MaxEntries: 3})
+			if !errors.Is(err, test.want) {
+				t.Errorf("Extract() error = %v, want %v", err, test.want)
+			}
+			if IsExist(filepath.Join(root, "evil")) {
+				t.Errorf("Extract() wrote outside the destination")
+			}
+		})
+	}
+}
+
+func TestExtractZipSlip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("../../evil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("evil"))
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "slip.zip")
+	if err = os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = Extract(archive, filepath.Join(t.TempDir(), "a", "b")); !errors.Is(err, errPathEscapes) {
+		t.Errorf("Extract() error = %v, want %v", err, errPathEscapes)
+	}
+}
@@ -0,0 +1,115 @@
+package file
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	errWriterClosed = errors.New("atomic writer already closed")
+)
+
+// AtomicWriter writes to a temporary file in the same directory as the
+// target and replaces the target with it on Close, so readers observe
+// either the old content or the new content, never a torn file.
+type AtomicWriter struct {
+	path   string
+	perm   fs.FileMode
+	f      *os.File
+	err    error
+	closed bool
+}
+
+// NewAtomicWriter creates a temporary file next to path. Nothing is visible
+// at path until Close is called successfully; Abort discards the data.
+func NewAtomicWriter(path string, perm fs.FileMode) (*AtomicWriter, error) {
+	path = filepath.Clean(path)
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicWriter{
+		path: path,
+		perm: perm,
+		f:    f,
+	}, nil
+}
+
+// Name returns the name of the target file.
+func (w *AtomicWriter) Name() string {
+	return w.path
+}
+
+// Write writes len(b) bytes to the temporary file. The first error is
+// remembered and makes Close discard the temporary file.
+func (w *AtomicWriter) Write(b []byte) (int, error) {
+	if w.closed {
+		return 0, &fs.PathError{Op: "write", Path: w.path, Err: errWriterClosed}
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.f.Write(b)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// Close syncs the temporary file, renames it over the target and syncs
+// the parent directory. If any step fails the temporary file is removed
+// and the target is left untouched.
+func (w *AtomicWriter) Close() error {
+	if w.closed {
+		return &fs.PathError{Op: "close", Path: w.path, Err: errWriterClosed}
+	}
+	w.closed = true
+	tmp := w.f.Name()
+	err := w.err
+	if err == nil {
+		err = w.f.Chmod(w.perm)
+	}
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(w.path))
+}
+
+// Abort discards the temporary file and leaves the target untouched.
+// It is a no-op after Close.
+func (w *AtomicWriter) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	tmp := w.f.Name()
+	_ = w.f.Close()
+	return os.Remove(tmp)
+}
+
+// WriteFileAtomic writes data to the named file atomically, like os.WriteFile
+// but without exposing a partially written file to concurrent readers or
+// after a crash.
+func WriteFileAtomic(path string, data []byte, perm fs.FileMode) error {
+	w, err := NewAtomicWriter(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		_ = w.Abort()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,83 @@
+//go:build linux
+
+package file
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxKernelCopy bounds a single copy_file_range or sendfile call.
+const maxKernelCopy = 1 << 30
+
+// copyContents copies src to dst, which must both be positioned at offset
+// zero, trying the kernel-assisted methods in order of preference. Whatever
+// the kernel could not copy is finished with io.Copy.
+func copyContents(dst, src *os.File, size int64) (int64, error) {
+	dfd, sfd := int(dst.Fd()), int(src.Fd())
+
+	if size > 0 && unix.IoctlFileClone(dfd, sfd) == nil {
+		if _, err := src.Seek(size, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := dst.Seek(size, io.SeekStart); err != nil {
+			return 0, err
+		}
+		n, err := io.Copy(dst, src)
+		return size + n, err
+	}
+
+	var written int64
+	for _, kernelCopy := range []func(int, int, int) (int, error){copyFileRange, sendfile} {
+		for written < size {
+			n, err := kernelCopy(dfd, sfd, int(min(size-written, maxKernelCopy)))
+			if err != nil {
+				if written == 0 && isKernelCopyUnsupported(err) {
+					break
+				}
+				return written, &os.PathError{Op: "copy", Path: dst.Name(), Err: err}
+			}
+			if n == 0 {
+				break
+			}
+			written += int64(n)
+		}
+		if written > 0 {
+			break
+		}
+	}
+	n, err := io.Copy(dst, src)
+	return written + n, err
+}
+
+func copyFileRange(dfd, sfd, size int) (int, error) {
+	for {
+		n, err := unix.CopyFileRange(sfd, nil, dfd, nil, size, 0)
+		if err != unix.EINTR {
+			return n, err
+		}
+	}
+}
+
+func sendfile(dfd, sfd, size int) (int, error) {
+	for {
+		n, err := unix.Sendfile(dfd, sfd, nil, size)
+		if err != unix.EINTR {
+			return n, err
+		}
+	}
+}
+
+// isKernelCopyUnsupported reports whether err means the method cannot be
+// used for this pair of files, as opposed to a genuine I/O failure.
+func isKernelCopyUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOSYS) ||
+		errors.Is(err, unix.EXDEV) ||
+		errors.Is(err, unix.EINVAL) ||
+		errors.Is(err, unix.EOPNOTSUPP) ||
+		errors.Is(err, unix.EPERM) ||
+		errors.Is(err, unix.EBADF)
+}
@@ -0,0 +1,208 @@
+package file
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	errNotDir      = errors.New("not a directory")
+	errDstInSrc    = errors.New("destination is inside the source directory")
+	errSymlinkLoop = errors.New("symbolic link loop")
+)
+
+// SymlinkMode controls how CopyDir handles symbolic links.
+type SymlinkMode int8
+
+const (
+	// SymlinkCopy recreates the link itself in the destination.
+	SymlinkCopy SymlinkMode = iota
+	// SymlinkFollow copies the file or directory the link points to.
+	SymlinkFollow
+	// SymlinkSkip ignores symbolic links.
+	SymlinkSkip
+)
+
+// FilterFunc reports whether an entry matches. The path is relative to the
+// root being processed and always uses forward slashes.
+type FilterFunc func(path string, d fs.DirEntry) bool
+
+// CopyDirOptions configures CopyDir. The zero value copies links as links
+// and does not preserve any metadata.
+type CopyDirOptions struct {
+	// Symlinks selects how symbolic links are handled.
+	Symlinks SymlinkMode
+
+	// PreserveMode keeps the permission bits of files and directories.
+	PreserveMode bool
+
+	// PreserveTimes keeps the modification time of files and directories.
+	PreserveTimes bool
+
+	// PreserveOwner keeps the owner and group, where the platform supports it.
+	PreserveOwner bool
+
+	// Include, if set, is called for every non-directory entry; entries it
+	// rejects are not copied.
+	Include FilterFunc
+
+	// Exclude, if set, is called for every entry; a matching directory is
+	// skipped together with everything below it.
+	Exclude FilterFunc
+}
+
+// CopyDir recursively copies the directory tree rooted at src to dst,
+// creating dst if needed and merging into it if it already exists.
+// Entries that are neither regular files, directories nor symbolic
+// links (devices, sockets, pipes) are skipped.
+func CopyDir(src, dst string, opts ...CopyDirOptions) error {
+	o := optional(opts)
+	cleanSrc := filepath.Clean(src)
+	cleanDst := filepath.Clean(dst)
+	fi, err := os.Stat(cleanSrc)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return &fs.PathError{Op: "CopyDir", Path: cleanSrc, Err: errNotDir}
+	}
+	if r, err := filepath.Rel(cleanSrc, cleanDst); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return &fs.PathError{Op: "CopyDir", Path: cleanDst, Err: errDstInSrc}
+	}
+	c := &dirCopier{opts: &o}
+	return c.copyDir(cleanSrc, cleanDst, ".", fi, nil)
+}
+
+type dirCopier struct {
+	opts *CopyDirOptions
+}
+
+func (c *dirCopier) copyDir(src, dst, rel string, fi fs.FileInfo, ancestors []string) error {
+	if c.opts.Symlinks == SymlinkFollow {
+		real, err := filepath.EvalSymlinks(src)
+		if err != nil {
+			return err
+		}
+		for _, a := range ancestors {
+			if a == real {
+				return &fs.PathError{Op: "CopyDir", Path: src, Err: errSymlinkLoop}
+			}
+		}
+		ancestors = append(ancestors[:len(ancestors):len(ancestors)], real)
+	}
+
+	if err := os.MkdirAll(dst, DefaultFileMode); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, d := range entries {
+		name := d.Name()
+		s, t := filepath.Join(src, name), filepath.Join(dst, name)
+		r := path.Join(rel, name)
+		if c.opts.Exclude != nil && c.opts.Exclude(r, d) {
+			continue
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			switch c.opts.Symlinks {
+			case SymlinkSkip:
+				continue
+			case SymlinkFollow:
+				info, err := os.Stat(s)
+				if err != nil {
+					return err
+				}
+				d = fs.FileInfoToDirEntry(info)
+			default:
+				if err = c.copySymlink(s, t, r, d); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err = c.copyEntry(s, t, r, d, ancestors); err != nil {
+			return err
+		}
+	}
+	return c.copyMetadata(dst, fi)
+}
+
+func (c *dirCopier) copyEntry(src, dst, rel string, d fs.DirEntry, ancestors []string) error {
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+	switch {
+	case d.IsDir():
+		return c.copyDir(src, dst, rel, info, ancestors)
+	case d.Type().IsRegular():
+		if c.opts.Include != nil && !c.opts.Include(rel, d) {
+			return nil
+		}
+		if _, err = CopyFile(src, dst); err != nil {
+			return err
+		}
+		return c.copyMetadata(dst, info)
+	default:
+		return nil
+	}
+}
+
+func (c *dirCopier) copySymlink(src, dst, rel string, d fs.DirEntry) error {
+	if c.opts.Include != nil && !c.opts.Include(rel, d) {
+		return nil
+	}
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err = os.Symlink(target, dst); err != nil {
+		return err
+	}
+	if c.opts.PreserveOwner {
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if uid, gid, ok := fileOwner(info); ok {
+			return os.Lchown(dst, uid, gid)
+		}
+	}
+	return nil
+}
+
+func (c *dirCopier) copyMetadata(dst string, fi fs.FileInfo) error {
+	return copyMetadata(dst, fi, c.opts.PreserveMode, c.opts.PreserveTimes, c.opts.PreserveOwner)
+}
+
+// copyMetadata applies the selected attributes of fi to dst. Ownership is
+// applied first because changing it may clear the setuid and setgid bits.
+func copyMetadata(dst string, fi fs.FileInfo, mode, times, owner bool) error {
+	if owner {
+		if uid, gid, ok := fileOwner(fi); ok {
+			if err := os.Lchown(dst, uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	if mode {
+		perm := fi.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+		if err := os.Chmod(dst, perm); err != nil {
+			return err
+		}
+	}
+	if times {
+		return os.Chtimes(dst, time.Time{}, fi.ModTime())
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package file
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	writeTree(t, src, map[string]string{
+		"a.txt":         "a",
+		"sub/b.txt":     "b",
+		"sub/c.log":     "c",
+		"skip/d.txt":    "d",
+		"sub/deep/e.go": "e",
+	})
+	if err := os.Chmod(filepath.Join(src, "a.txt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "sub/b.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := CopyDir(src, dst, CopyDirOptions{
+		PreserveMode:  true,
+		PreserveTimes: true,
+		Exclude: func(p string, d os.DirEntry) bool {
+			return p == "skip"
+		},
+		Include: func(p string, d os.DirEntry) bool {
+			return path.Ext(p) != ".log"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{
+		"a.txt":         true,
+		"sub/b.txt":     true,
+		"sub/c.log":     false,
+		"skip":          false,
+		"sub/deep/e.go": true,
+	} {
+		if got := IsExist(filepath.Join(dst, name)); got != want {
+			t.Errorf("IsExist(%s) = %t, want %t", name, got, want)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "a.txt" {
+		t.Errorf("Readlink(link) = %q, %v, want %q", target, err, "a.txt")
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "a.txt")); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("a.txt mode = %v, %v, want %v", fi.Mode().Perm(), err, os.FileMode(0o600))
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "sub/b.txt")); err != nil || !fi.ModTime().Equal(mtime) {
+		t.Errorf("b.txt mtime = %v, %v, want %v", fi.ModTime(), err, mtime)
+	}
+}
+
+func TestCopyDirSymlinks(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"dir/f.txt": "f"})
+	if err := os.Symlink("dir", filepath.Join(src, "alias")); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "follow")
+	if err := CopyDir(src, dst, CopyDirOptions{Symlinks: SymlinkFollow}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(filepath.Join(dst, "alias")); err != nil || !fi.IsDir() {
+		t.Errorf("alias should be copied as a directory: %v", err)
+	}
+
+	dst = filepath.Join(t.TempDir(), "skip")
+	if err := CopyDir(src, dst, CopyDirOptions{Symlinks: SymlinkSkip}); err != nil {
+		t.Fatal(err)
+	}
+	if IsExist(filepath.Join(dst, "alias")) {
+		t.Errorf("alias should be skipped")
+	}
+
+	if err := os.Symlink("..", filepath.Join(src, "dir", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	dst = filepath.Join(t.TempDir(), "loop")
+	if err := CopyDir(src, dst, CopyDirOptions{Symlinks: SymlinkFollow}); err == nil {
+		t.Errorf("expected symbolic link loop error")
+	}
+	if err := CopyDir(src, filepath.Join(src, "dir", "copy")); err == nil {
+		t.Errorf("expected error copying a directory into itself")
+	}
+}
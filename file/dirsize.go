@@ -0,0 +1,138 @@
+package file
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// DirSizeOptions configures DirSize. The zero value reads one directory per
+// CPU at a time.
+type DirSizeOptions struct {
+	// Parallel sets how many directories may be read at once.
+	Parallel int
+}
+
+// DirSizeInfo is the space used by a directory tree.
+type DirSizeInfo struct {
+	// Size is the apparent size, the sum of the lengths reported for every
+	// entry. Like du, it includes the directories themselves.
+	Size int64
+
+	// Allocated is the space allocated on disk, which is smaller than Size
+	// for sparse and compressed files. It equals Size on platforms that do
+	// not report allocated blocks.
+	Allocated int64
+
+	// Files counts everything that is not a directory, including symbolic
+	// links, which are not followed.
+	Files int64
+
+	// Dirs counts the directories, including the root.
+	Dirs int64
+}
+
+// DirSize returns the space used by the tree at path, reading directories
+// concurrently. On Unix, a file with several hard links in the tree is
+// counted once; elsewhere, including Windows, file identities are not
+// available and every link is counted.
+//
+// DirSize does not stop at unreadable entries; it returns the totals of what
+// it could read along with the joined errors.
+func DirSize(path string, opts ...DirSizeOptions) (DirSizeInfo, error) {
+	o := optional(opts)
+	if o.Parallel <= 0 {
+		o.Parallel = runtime.NumCPU()
+	}
+	s := &sizer{workers: newWorkers(o.Parallel), seen: make(map[fileID]bool)}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return DirSizeInfo{}, err
+	}
+	s.add(fi)
+	if fi.IsDir() {
+		s.walkDir(path)
+	}
+	err = s.wait()
+	return s.info, err
+}
+
+type sizer struct {
+	*workers
+
+	mu   sync.Mutex
+	info DirSizeInfo
+	seen map[fileID]bool
+}
+
+func (s *sizer) walkDir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	for _, d := range entries {
+		p := filepath.Join(dir, d.Name())
+		fi, err := d.Info()
+		if err != nil {
+			if !os.IsNotExist(err) {
+				s.fail(err)
+			}
+			continue
+		}
+		s.add(fi)
+		if d.IsDir() {
+			s.do(func() { s.walkDir(p) })
+		}
+	}
+}
+
+func (s *sizer) add(fi fs.FileInfo) {
+	id, links, allocated, ok := fileBlocks(fi)
+	if !ok {
+		allocated = fi.Size()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ok && links > 1 && !fi.IsDir() {
+		if s.seen[id] {
+			return
+		}
+		s.seen[id] = true
+	}
+	if fi.IsDir() {
+		s.info.Dirs++
+	} else {
+		s.info.Files++
+	}
+	s.info.Size += fi.Size()
+	s.info.Allocated += allocated
+}
+
+// DiskUsage describes the file system holding a path. Available is the
+// space usable by unprivileged users, which may be less than Free.
+type DiskUsage struct {
+	Total     uint64
+	Free      uint64
+	Available uint64
+
+	// Inodes and InodesFree are zero on platforms without inodes.
+	Inodes     uint64
+	InodesFree uint64
+}
+
+// Used returns the number of bytes in use.
+func (u DiskUsage) Used() uint64 {
+	return u.Total - u.Free
+}
+
+// Usage returns the size and free space of the file system holding path.
+func Usage(path string) (DiskUsage, error) {
+	u, err := usage(path)
+	if err != nil {
+		return DiskUsage{}, &fs.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	return u, nil
+}
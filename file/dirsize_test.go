@@ -0,0 +1,60 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDirSize(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.txt":       "hello",
+		"sub/b.txt":   "world!",
+		"sub/c/d.txt": "0123456789",
+	})
+	if runtime.GOOS != "windows" {
+		if err := os.Link(filepath.Join(root, "a.txt"), filepath.Join(root, "sub/link.txt")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirs := int64(0)
+	for _, dir := range []string{"", "sub", "sub/c"} {
+		fi, err := os.Stat(filepath.Join(root, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs += fi.Size()
+	}
+	for _, parallel := range []int{1, 4} {
+		info, err := DirSize(root, DirSizeOptions{Parallel: parallel})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size-dirs != 21 || info.Files != 3 || info.Dirs != 3 {
+			t.Errorf("DirSize(Parallel: %d) = %+v, want Size %d, Files 3, Dirs 3", parallel, info, dirs+21)
+		}
+		if info.Allocated <= 0 {
+			t.Errorf("DirSize(Parallel: %d).Allocated = %d, want > 0", parallel, info.Allocated)
+		}
+	}
+
+	info, err := DirSize(filepath.Join(root, "a.txt"))
+	if err != nil || info.Size != 5 || info.Files != 1 || info.Dirs != 0 {
+		t.Errorf("DirSize(file) = %+v, %v", info, err)
+	}
+	if _, err = DirSize(filepath.Join(root, "missing")); !os.IsNotExist(err) {
+		t.Errorf("DirSize(missing) error = %v, want not exist", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	u, err := Usage(t.TempDir())
+	if err != nil {
+		t.Skip(err)
+	}
+	if u.Total == 0 || u.Free > u.Total || u.Available > u.Total || u.Used() > u.Total {
+		t.Errorf("Usage() = %+v", u)
+	}
+}
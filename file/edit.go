@@ -0,0 +1,155 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	ubufio "github.com/nexuer/utils/bufio"
+)
+
+// EditLinesOptions configures EditLines. The zero value keeps no backup.
+type EditLinesOptions struct {
+	// Backup keeps the original file as path+".bak" when it is changed.
+	Backup bool
+
+	// MaxLineSize is the size of the longest line that can be edited,
+	// including its line ending. It defaults to bufio.MaxScanTokenSize,
+	// 64 KiB; a longer line makes EditLines fail with bufio.ErrTooLong.
+	MaxLineSize int
+}
+
+// EditLines rewrites the file at path line by line. fn is called for every
+// line, as read by ReadLineFunc but limited to MaxLineSize, and returns the line to write in its place
+// and whether to keep it at all. If fn returns an error, the file is left
+// untouched.
+//
+// The new content is written atomically and keeps the mode and owner of
+// the original. If the caller may not give the file to its owner, as when
+// editing a group-writable file owned by someone else, the edited file
+// belongs to the caller instead. The line ending of the first line, LF or CRLF, is used for
+// every line, and a missing newline at the end of the file stays missing.
+// If path is a symbolic link, the file it points to is edited. Nothing is
+// written when fn changes nothing.
+func EditLines(path string, fn func(num int, line string) (string, bool, error), opts ...EditLinesOptions) (err error) {
+	o := optional(opts)
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(real)
+	if err != nil {
+		return err
+	}
+	newline, trailing, err := lineEndings(real, fi.Size())
+	if err != nil {
+		return err
+	}
+
+	w, err := NewAtomicWriter(real, fi.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = w.Abort()
+		}
+	}()
+	src, err := os.Open(real)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	bw := bufio.NewWriter(w)
+	changed, written := false, 0
+	err = ubufio.ReadLineFunc(src, func(num int, line string) error {
+		edited, keep, err := fn(num, line)
+		if err != nil {
+			return err
+		}
+		if !keep {
+			changed = true
+			return nil
+		}
+		changed = changed || edited != line
+		if written > 0 {
+			bw.WriteString(newline)
+		}
+		written++
+		_, err = bw.WriteString(edited)
+		return err
+	}, ubufio.ReadLineOptions{MaxLineSize: o.MaxLineSize})
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return w.Abort()
+	}
+	if trailing && written > 0 {
+		bw.WriteString(newline)
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if err = keepOwner(w.f, fi); err != nil {
+		return err
+	}
+	if o.Backup {
+		if _, err = CopyFile(real, real+".bak", CopyFileOptions{PreserveMode: true, PreserveTimes: true}); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// keepOwner gives f the owner and group of the original file fi. Changing
+// them is only attempted when they differ, and a permission error is not
+// fatal.
+func keepOwner(f *os.File, fi fs.FileInfo) error {
+	uid, gid, ok := fileOwner(fi)
+	if !ok {
+		return nil
+	}
+	tfi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if tuid, tgid, ok := fileOwner(tfi); ok && tuid == uid && tgid == gid {
+		return nil
+	}
+	if err = f.Chown(uid, gid); err != nil && !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	return nil
+}
+
+// lineEndings returns the line ending used by the first line of the file
+// and whether the file ends with a newline.
+func lineEndings(path string, size int64) (newline string, trailing bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	newline = "\n"
+	buf := make([]byte, min(size, 64*1024))
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", false, err
+	}
+	if i := bytes.IndexByte(buf[:n], '\n'); i > 0 && buf[i-1] == '\r' {
+		newline = "\r\n"
+	}
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err = f.ReadAt(last, size-1); err != nil {
+			return "", false, err
+		}
+		trailing = last[0] == '\n'
+	}
+	return newline, trailing, nil
+}
@@ -0,0 +1,122 @@
+package file
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestEditLines(t *testing.T) {
+	// Comment out "b" lines, drop "c" lines and upper-case the rest.
+	edit := func(num int, line string) (string, bool, error) {
+		switch {
+		case strings.HasPrefix(line, "b"):
+			return "# " + line, true, nil
+		case strings.HasPrefix(line, "c"):
+			return "", false, nil
+		}
+		return strings.ToUpper(line), true, nil
+	}
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "a1\nb1\nc1\na2\n", want: "A1\n# b1\nA2\n"},
+		{content: "a1\r\nb1\r\nc1", want: "A1\r\n# b1"},
+		{content: "c1\nc2\n", want: ""},
+		{content: "", want: ""},
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts")
+	for _, test := range tests {
+		if err := os.WriteFile(path, []byte(test.content), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		if err := EditLines(path, edit); err != nil {
+			t.Fatalf("EditLines(%q) error = %v", test.content, err)
+		}
+		if b, _ := os.ReadFile(path); string(b) != test.want {
+			t.Errorf("EditLines(%q) wrote %q, want %q", test.content, b, test.want)
+		}
+		if fi, err := os.Stat(path); runtime.GOOS != "windows" && (err != nil || fi.Mode().Perm() != 0o640) {
+			t.Errorf("mode after EditLines = %v, %v; want 0640", fi.Mode(), err)
+		}
+	}
+	if IsExist(path + ".bak") {
+		t.Errorf("backup written without the Backup option")
+	}
+
+	if err := os.WriteFile(path, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := EditLines(path, edit, EditLinesOptions{Backup: true}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(path + ".bak"); err != nil || string(b) != "a\nb\n" {
+		t.Errorf("backup = %q, %v; want the original", b, err)
+	}
+
+	failed := errors.New("failed")
+	err := EditLines(path, func(num int, line string) (string, bool, error) {
+		return "", false, failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("EditLines() error = %v, want %v", err, failed)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "A\n# b\n" {
+		t.Errorf("failed EditLines changed the file to %q", b)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestEditLinesSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need extra privileges on windows")
+	}
+	dir := t.TempDir()
+	target, link := filepath.Join(dir, "target"), filepath.Join(dir, "link")
+	if err := os.WriteFile(target, []byte("x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("target", link); err != nil {
+		t.Fatal(err)
+	}
+	err := EditLines(link, func(num int, line string) (string, bool, error) {
+		return "y", true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("EditLines replaced the link")
+	}
+	if b, _ := os.ReadFile(target); string(b) != "y\n" {
+		t.Errorf("target = %q, want %q", b, "y\n")
+	}
+}
+
+func TestEditLinesLongLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	long := strings.Repeat("x", 100*1024)
+	if err := os.WriteFile(path, []byte("a\n"+long+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	upper := func(num int, line string) (string, bool, error) {
+		return strings.ToUpper(line), true, nil
+	}
+	if err := EditLines(path, upper); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("EditLines() of a 100 KiB line = %v, want %v", err, bufio.ErrTooLong)
+	}
+	if err := EditLines(path, upper, EditLinesOptions{MaxLineSize: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "A\n"+strings.ToUpper(long)+"\n" {
+		t.Errorf("EditLines() with MaxLineSize did not edit the long line")
+	}
+}
@@ -1,10 +1,12 @@
 package file
 
 import (
-	"io"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/nexuer/utils/bufio"
 )
@@ -13,6 +15,9 @@ const (
 	DefaultFileMode = 0o755
 )
 
+// errTailDone stops ReverseLineFunc once TailLines has enough lines.
+var errTailDone = errors.New("tail done")
+
 func getFileMode(perm ...fs.FileMode) fs.FileMode {
 	if len(perm) > 0 {
 		return perm[0]
@@ -20,6 +25,14 @@ func getFileMode(perm ...fs.FileMode) fs.FileMode {
 	return DefaultFileMode
 }
 
+func optional[T any](opts []T) T {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	var zero T
+	return zero
+}
+
 // CreateIfNotExists creates a file or a directory only if it does not already exist.
 func CreateIfNotExists(path string, isDir bool, perm ...fs.FileMode) error {
 	mode := getFileMode(perm...)
@@ -41,10 +54,133 @@ func CreateIfNotExists(path string, isDir bool, perm ...fs.FileMode) error {
 	return nil
 }
 
+// CreateOptions configures CreateIfNotExistsWithOptions. The zero value
+// creates an empty regular file with mode 0o644, creating any missing parent
+// directories with DefaultFileMode.
+type CreateOptions struct {
+	// IsDir creates a directory instead of a regular file.
+	IsDir bool
+
+	// DirMode is the mode for created directories, including missing
+	// parents. FileMode is the mode for a created file. Both are subject
+	// to the umask.
+	DirMode  fs.FileMode
+	FileMode fs.FileMode
+
+	// Exclusive makes an existing path an error wrapping fs.ErrExist, like
+	// O_EXCL, rather than a success with created == false.
+	Exclusive bool
+
+	// Chown sets the owner of everything created, including missing
+	// parents, to UID and GID. It is not supported on Windows and Plan 9.
+	Chown bool
+	UID   int
+	GID   int
+}
+
+// CreateIfNotExistsWithOptions is like CreateIfNotExists, but uses separate
+// modes for directories and files and reports whether path was created.
+// Creation is atomic: if several callers race, exactly one of them gets
+// created == true. An existing path of the other type, such as a file
+// when IsDir is set, is an error.
+func CreateIfNotExistsWithOptions(path string, opts CreateOptions) (created bool, err error) {
+	if opts.DirMode == 0 {
+		opts.DirMode = DefaultFileMode
+	}
+	if opts.FileMode == 0 {
+		opts.FileMode = 0o644
+	}
+	path = filepath.Clean(path)
+	if err = mkdirParents(filepath.Dir(path), opts); err != nil {
+		return false, err
+	}
+
+	if opts.IsDir {
+		err = os.Mkdir(path, opts.DirMode)
+	} else {
+		var f *os.File
+		if f, err = os.OpenFile(path, os.O_RDONLY|os.O_CREATE|os.O_EXCL, opts.FileMode); err == nil {
+			err = f.Close()
+		}
+	}
+	if errors.Is(err, fs.ErrExist) && !opts.Exclusive {
+		// An existing path only counts if it has the requested type.
+		fi, serr := os.Stat(path)
+		switch {
+		case serr != nil:
+			return false, serr
+		case opts.IsDir && !fi.IsDir():
+			return false, &fs.PathError{Op: "mkdir", Path: path, Err: errNotDir}
+		case !opts.IsDir && fi.IsDir():
+			return false, &fs.PathError{Op: "open", Path: path, Err: errIsDir}
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if opts.Chown {
+		if err = os.Chown(path, opts.UID, opts.GID); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// mkdirParents creates dir and its missing parents like os.MkdirAll,
+// changing the owner of each directory it creates if asked to.
+func mkdirParents(dir string, opts CreateOptions) error {
+	fi, err := os.Stat(dir)
+	if err == nil {
+		if !fi.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errNotDir}
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err = mkdirParents(parent, opts); err != nil {
+			return err
+		}
+	}
+	if err = os.Mkdir(dir, opts.DirMode); err != nil {
+		if os.IsExist(err) && IsDir(dir) {
+			// Created concurrently.
+			return nil
+		}
+		return err
+	}
+	if opts.Chown {
+		return os.Chown(dir, opts.UID, opts.GID)
+	}
+	return nil
+}
+
+// CopyFileOptions configures CopyFile. The zero value copies only the
+// content of the file.
+type CopyFileOptions struct {
+	// PreserveMode keeps the permission bits of src.
+	PreserveMode bool
+
+	// PreserveTimes keeps the modification time of src.
+	PreserveTimes bool
+
+	// Sparse copies only the data regions of src, so that holes stay
+	// unallocated in dst. The returned count still includes the holes.
+	Sparse bool
+}
+
 // CopyFile copies from src to dst until either EOF is reached
 // on src or an error occurs. It verifies src exists and removes
 // the dst if it exists.
-func CopyFile(src, dst string) (int64, error) {
+//
+// On Linux the copy is done in the kernel when possible, trying a reflink
+// clone first, then copy_file_range and sendfile, before falling back to
+// copying through user space.
+func CopyFile(src, dst string, opts ...CopyFileOptions) (int64, error) {
+	o := optional(opts)
 	cleanSrc := filepath.Clean(src)
 	cleanDst := filepath.Clean(dst)
 	if cleanSrc == cleanDst {
@@ -55,6 +191,10 @@ func CopyFile(src, dst string) (int64, error) {
 		return 0, err
 	}
 	defer sf.Close()
+	fi, err := sf.Stat()
+	if err != nil {
+		return 0, err
+	}
 	if err = os.Remove(cleanDst); err != nil && !os.IsNotExist(err) {
 		return 0, err
 	}
@@ -62,8 +202,19 @@ func CopyFile(src, dst string) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	defer df.Close()
-	return io.Copy(df, sf)
+	var n int64
+	if o.Sparse {
+		n, err = copySparse(df, sf, fi.Size())
+	} else {
+		n, err = copyContents(df, sf, fi.Size())
+	}
+	if cerr := df.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, copyMetadata(cleanDst, fi, o.PreserveMode, o.PreserveTimes, false)
 }
 
 // ReadLineFunc read the file line by line and call f(c) to process each line of string
@@ -88,6 +239,42 @@ func ReadLineBytesFunc(path string, f func(num int, line []byte) error) error {
 	return bufio.ReadLineBytesFunc(file, f)
 }
 
+// ReverseLineFunc read the file backwards line by line and call f(c) to process each line of string,
+// numbering lines from the end of the file
+func ReverseLineFunc(path string, f func(num int, line string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return bufio.ReverseLineFunc(file, fi.Size(), f)
+}
+
+// TailLines returns the last n lines of the file, in file order
+func TailLines(path string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	lines := make([]string, 0, min(n, 1024))
+	err := ReverseLineFunc(path, func(num int, line string) error {
+		lines = append(lines, strings.Clone(line))
+		if num == n {
+			return errTailDone
+		}
+		return nil
+	})
+	if err != nil && err != errTailDone {
+		return nil, err
+	}
+	slices.Reverse(lines)
+	return lines, nil
+}
+
 // IsExistE returns whether this path exist and error
 func IsExistE(path string) (bool, error) {
 	_, err := os.Stat(path)
@@ -1,6 +1,17 @@
 package file
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestIsExistE(t *testing.T) {
 	tests := []struct {
@@ -23,3 +34,154 @@ func TestIsExistE(t *testing.T) {
 		}
 	}
 }
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	if err := os.WriteFile(src, data, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("stale"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := CopyFile(src, dst, CopyFileOptions{PreserveMode: true, PreserveTimes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("CopyFile() = %d, want %d", n, len(data))
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("copied content differs from source")
+	}
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0o640))
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", fi.ModTime(), mtime)
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	long := strings.Repeat("x", 40*1024)
+	tests := []struct {
+		content string
+		n       int
+		want    []string
+	}{
+		{content: "", n: 3, want: []string{}},
+		{content: "a\nb\nc\n", n: 2, want: []string{"b", "c"}},
+		{content: "a\nb\nc", n: 2, want: []string{"b", "c"}},
+		{content: "a\r\nb\r\nc\r\n", n: 5, want: []string{"a", "b", "c"}},
+		{content: "a\n\nb\n\n", n: 3, want: []string{"", "b", ""}},
+		{content: "\n", n: 1, want: []string{""}},
+		{content: "a\n" + long + "\nb", n: 2, want: []string{long, "b"}},
+		{content: long + "\n" + long, n: 3, want: []string{long, long}},
+	}
+
+	path := filepath.Join(t.TempDir(), "log")
+	for i, test := range tests {
+		if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := TailLines(path, test.n)
+		if err != nil {
+			t.Fatalf("%d: TailLines() error = %v", i, err)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%d: TailLines() = %q, want %q", i, got, test.want)
+		}
+
+		// Reading forward must agree with reading backward.
+		var forward []string
+		_ = ReadLineFunc(path, func(num int, line string) error {
+			forward = append(forward, strings.Clone(line))
+			return nil
+		})
+		var nums []int
+		err = ReverseLineFunc(path, func(num int, line string) error {
+			nums = append(nums, num)
+			if want := strings.TrimSuffix(forward[len(forward)-num], "\r"); line != want {
+				t.Errorf("%d: line %d from the end = %.20q, want %.20q", i, num, line, want)
+			}
+			return nil
+		})
+		if err != nil || len(nums) != len(forward) {
+			t.Errorf("%d: ReverseLineFunc() read %d lines, %v; want %d", i, len(nums), err, len(forward))
+		}
+	}
+}
+
+func TestCreateIfNotExistsWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "a", "b", "secret")
+	created, err := CreateIfNotExistsWithOptions(secret, CreateOptions{DirMode: 0o750, FileMode: 0o600})
+	if err != nil || !created {
+		t.Fatalf("CreateIfNotExistsWithOptions() = %t, %v; want true, nil", created, err)
+	}
+	if runtime.GOOS != "windows" {
+		// The modes are subject to the umask, found out with a probe.
+		probe := filepath.Join(dir, "probe")
+		if err = os.WriteFile(probe, nil, 0o777); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(probe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		umask := 0o777 &^ fi.Mode().Perm()
+		for p, want := range map[string]os.FileMode{
+			filepath.Join(dir, "a"):      0o750&^umask | os.ModeDir,
+			filepath.Join(dir, "a", "b"): 0o750&^umask | os.ModeDir,
+			secret:                       0o600 &^ umask,
+		} {
+			if fi, err := os.Stat(p); err != nil || fi.Mode() != want {
+				t.Errorf("mode of %s = %v, %v; want %v", p, fi.Mode(), err, want)
+			}
+		}
+	}
+
+	created, err = CreateIfNotExistsWithOptions(secret, CreateOptions{})
+	if err != nil || created {
+		t.Errorf("CreateIfNotExistsWithOptions(existing) = %t, %v; want false, nil", created, err)
+	}
+	created, err = CreateIfNotExistsWithOptions(secret, CreateOptions{Exclusive: true})
+	if !errors.Is(err, fs.ErrExist) || created {
+		t.Errorf("CreateIfNotExistsWithOptions(existing, Exclusive) = %t, %v; want false, ErrExist", created, err)
+	}
+
+	sub := filepath.Join(dir, "a", "c")
+	created, err = CreateIfNotExistsWithOptions(sub, CreateOptions{IsDir: true, Chown: true, UID: os.Getuid(), GID: os.Getgid()})
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		if err == nil {
+			t.Errorf("CreateIfNotExistsWithOptions(Chown) should fail on %s", runtime.GOOS)
+		}
+		return
+	}
+	if err != nil || !created || !IsDir(sub) {
+		t.Errorf("CreateIfNotExistsWithOptions(dir) = %t, %v; want true, nil", created, err)
+	}
+	if _, err = CreateIfNotExistsWithOptions(filepath.Join(secret, "x"), CreateOptions{}); err == nil {
+		t.Errorf("CreateIfNotExistsWithOptions() below a file should fail")
+	}
+	if created, err = CreateIfNotExistsWithOptions(secret, CreateOptions{IsDir: true}); err == nil || created {
+		t.Errorf("CreateIfNotExistsWithOptions(dir) over a file = %t, %v; want an error", created, err)
+	}
+	if created, err = CreateIfNotExistsWithOptions(sub, CreateOptions{}); err == nil || created {
+		t.Errorf("CreateIfNotExistsWithOptions(file) over a directory = %t, %v; want an error", created, err)
+	}
+}
@@ -0,0 +1,283 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultFollowInterval = 250 * time.Millisecond
+	defaultRotateGrace    = time.Second
+)
+
+// FollowStart selects where a Follower starts reading.
+type FollowStart int8
+
+const (
+	// FollowFromEnd skips the existing content and reads only new lines.
+	FollowFromEnd FollowStart = iota
+	// FollowFromStart reads the file from the beginning.
+	FollowFromStart
+	// FollowFromOffset resumes at FollowOptions.Offset, typically a value
+	// saved from Follower.Offset. If the file is now shorter than the
+	// offset, it has been truncated or replaced and is read from the start.
+	FollowFromOffset
+)
+
+// FollowOptions configures Follow. The zero value starts at the end of the
+// file, checks for new data four times a second and keeps reading a rotated
+// file until it has been idle for a second.
+type FollowOptions struct {
+	Start  FollowStart
+	Offset int64
+
+	// PollInterval is how long to wait at the end of the file before
+	// checking for new data, rotation and truncation.
+	PollInterval time.Duration
+
+	// RotateGrace is how long a rotated file is still read after it stops
+	// growing, so that lines written before the writer reopens the path
+	// are not lost.
+	RotateGrace time.Duration
+}
+
+// Follower reads lines appended to a file, like tail -F. It keeps reading
+// across rotation, when the path is replaced by a new file, and truncation.
+type Follower struct {
+	path   string
+	opts   FollowOptions
+	cur    *followedFile
+	offset atomic.Int64
+	num    int
+
+	// old is the file rotated away, read until it has been idle for
+	// RotateGrace, that is until oldIdle.
+	old     *followedFile
+	oldIdle time.Time
+}
+
+// followedFile is an open file being read line by line.
+type followedFile struct {
+	f  *os.File
+	fi os.FileInfo
+	r  *bufio.Reader
+
+	// partial holds the bytes of an unterminated last line.
+	partial []byte
+}
+
+// Follow opens the file at path for following.
+func Follow(path string, opts ...FollowOptions) (*Follower, error) {
+	fl := &Follower{path: path, opts: optional(opts)}
+	if fl.opts.PollInterval <= 0 {
+		fl.opts.PollInterval = defaultFollowInterval
+	}
+	if fl.opts.RotateGrace <= 0 {
+		fl.opts.RotateGrace = defaultRotateGrace
+	}
+	cur, err := openFollowed(path)
+	if err != nil {
+		return nil, err
+	}
+	var offset int64
+	switch fl.opts.Start {
+	case FollowFromEnd:
+		offset = cur.fi.Size()
+	case FollowFromOffset:
+		if fl.opts.Offset <= cur.fi.Size() {
+			offset = fl.opts.Offset
+		}
+	}
+	if _, err = cur.f.Seek(offset, io.SeekStart); err != nil {
+		cur.f.Close()
+		return nil, err
+	}
+	fl.cur = cur
+	fl.offset.Store(offset)
+	return fl, nil
+}
+
+// Offset returns the offset in the current file just past the last line
+// delivered. It may be saved and passed back with FollowFromOffset to
+// resume later, and is safe to call from the Run callback.
+func (fl *Follower) Offset() int64 {
+	return fl.offset.Load()
+}
+
+// Run calls f for every complete line, numbering lines from 1 across
+// rotations, until ctx is done or f returns an error. Lines have their
+// line ending removed. An unterminated line is held back until its newline
+// arrives, or delivered when its file is no longer read.
+//
+// After rotation, lines still appended to the old file are delivered
+// together with those of the new one until the old file has been idle for
+// RotateGrace.
+func (fl *Follower) Run(ctx context.Context, f func(num int, line string) error) error {
+	timer := time.NewTimer(fl.opts.PollInterval)
+	defer timer.Stop()
+	for {
+		if _, err := fl.readLines(fl.cur, f); err != nil {
+			return err
+		}
+		if err := fl.drainOld(f); err != nil {
+			return err
+		}
+		changed, err := fl.checkFile(f)
+		if err != nil {
+			return err
+		}
+		if changed {
+			continue
+		}
+		timer.Reset(fl.opts.PollInterval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Close closes the files being followed.
+func (fl *Follower) Close() error {
+	err := fl.cur.f.Close()
+	if fl.old != nil {
+		fl.old.f.Close()
+		fl.old = nil
+	}
+	return err
+}
+
+func openFollowed(path string) (*followedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &followedFile{f: f, fi: fi, r: bufio.NewReader(f)}, nil
+}
+
+// readLines delivers every complete line of ff available up to the end of
+// file and reports whether any data was read.
+func (fl *Follower) readLines(ff *followedFile, f func(num int, line string) error) (bool, error) {
+	read := false
+	for {
+		data, err := ff.r.ReadSlice('\n')
+		read = read || len(data) > 0
+		ff.partial = append(ff.partial, data...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			return read, nil
+		}
+		if err != nil {
+			return read, err
+		}
+		if err = fl.deliver(ff, f); err != nil {
+			return read, err
+		}
+	}
+}
+
+func (fl *Follower) deliver(ff *followedFile, f func(num int, line string) error) error {
+	n := len(ff.partial)
+	line := bytes.TrimSuffix(bytes.TrimSuffix(ff.partial, []byte{'\n'}), []byte{'\r'})
+	fl.num++
+	err := f(fl.num, string(line))
+	if ff == fl.cur {
+		fl.offset.Add(int64(n))
+	}
+	ff.partial = ff.partial[:0]
+	return err
+}
+
+// drainOld reads what was appended to the rotated file and closes it once
+// it has been idle for RotateGrace.
+func (fl *Follower) drainOld(f func(num int, line string) error) error {
+	if fl.old == nil {
+		return nil
+	}
+	read, err := fl.readLines(fl.old, f)
+	if err != nil {
+		return err
+	}
+	if read {
+		fl.oldIdle = time.Now().Add(fl.opts.RotateGrace)
+		return nil
+	}
+	if time.Now().Before(fl.oldIdle) {
+		return nil
+	}
+	return fl.closeOld(f)
+}
+
+// closeOld stops reading the rotated file, delivering its unterminated last
+// line if any.
+func (fl *Follower) closeOld(f func(num int, line string) error) error {
+	old := fl.old
+	fl.old = nil
+	old.f.Close()
+	if len(old.partial) > 0 {
+		return fl.deliver(old, f)
+	}
+	return nil
+}
+
+// checkFile detects rotation and truncation once the end of the current
+// file has been reached, and reports whether reading restarted.
+func (fl *Follower) checkFile(f func(num int, line string) error) (bool, error) {
+	fi, err := os.Stat(fl.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Rotated away and not recreated yet; keep reading the old file.
+			return false, nil
+		}
+		return false, err
+	}
+	if !os.SameFile(fi, fl.cur.fi) {
+		next, err := openFollowed(fl.path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return false, nil
+			}
+			return false, err
+		}
+		// Read what was written to the current file since the last read,
+		// then keep it open as the old file for late writes.
+		if _, err = fl.readLines(fl.cur, f); err != nil {
+			next.f.Close()
+			return false, err
+		}
+		if fl.old != nil {
+			if err = fl.closeOld(f); err != nil {
+				next.f.Close()
+				return false, err
+			}
+		}
+		fl.old, fl.cur = fl.cur, next
+		fl.oldIdle = time.Now().Add(fl.opts.RotateGrace)
+		fl.offset.Store(0)
+		return true, nil
+	}
+	if fi.Size() < fl.Offset()+int64(len(fl.cur.partial)) {
+		if _, err = fl.cur.f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		fl.cur.r.Reset(fl.cur.f)
+		fl.cur.partial = fl.cur.partial[:0]
+		fl.offset.Store(0)
+		return true, nil
+	}
+	return false, nil
+}
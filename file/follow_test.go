@@ -0,0 +1,150 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "old 1\nold 2\n")
+
+	fl, err := Follow(path, FollowOptions{Start: FollowFromOffset, Offset: 6, PollInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+
+	lines := make(chan string, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- fl.Run(ctx, func(num int, line string) error {
+			lines <- line
+			return nil
+		})
+	}()
+	expect := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case got := <-lines:
+				if got != w {
+					t.Fatalf("line = %q, want %q", got, w)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for %q", w)
+			}
+		}
+	}
+
+	expect("old 2")
+	appendFile(t, path, "new 1\r\nnew")
+	expect("new 1")
+	appendFile(t, path, " 2\n")
+	expect("new 2")
+
+	if err = os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "late\n")
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, path, "rotated\n")
+	expect("late", "rotated")
+
+	if err = os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, path, "truncated\n")
+	expect("truncated")
+	if off := fl.Offset(); off != int64(len("truncated\n")) {
+		t.Errorf("Offset() = %d, want %d", off, len("truncated\n"))
+	}
+
+	cancel()
+	if err = <-done; err != context.Canceled {
+		t.Errorf("Run() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestFollowLateWrites(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writer, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	fl, err := Follow(path, FollowOptions{PollInterval: 5 * time.Millisecond, RotateGrace: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+	lines := make(chan string, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- fl.Run(ctx, func(num int, line string) error {
+			lines <- line
+			return nil
+		})
+	}()
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Fatalf("line = %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+
+	// Rotate like logrotate's "create": the writer keeps appending to the
+	// renamed file until it reopens the path.
+	if _, err = writer.WriteString("before\n"); err != nil {
+		t.Fatal(err)
+	}
+	expect("before")
+	if err = os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "new\n")
+	expect("new")
+	if _, err = writer.WriteString("late 1\nlate"); err != nil {
+		t.Fatal(err)
+	}
+	expect("late 1")
+	time.Sleep(30 * time.Millisecond)
+	if _, err = writer.WriteString(" 2\n"); err != nil {
+		t.Fatal(err)
+	}
+	expect("late 2")
+
+	// Once idle for RotateGrace the old file is closed.
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	<-done
+	if fl.old != nil {
+		t.Errorf("rotated file still open after the grace period")
+	}
+}
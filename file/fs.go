@@ -0,0 +1,173 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+
+	"github.com/nexuer/utils/bufio"
+)
+
+// File is a file opened through an FS for reading or writing.
+type File interface {
+	fs.File
+	io.Writer
+}
+
+// FS is a writable file system. Names follow the io/fs conventions: they
+// are slash-separated, unrooted and must satisfy fs.ValidPath.
+type FS interface {
+	fs.StatFS
+
+	// OpenFile opens the named file with the given os.O_* flags, creating
+	// it with perm if os.O_CREATE is set.
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+
+	// MkdirAll creates the named directory along with any missing parents.
+	MkdirAll(name string, perm fs.FileMode) error
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+}
+
+type osFS string
+
+// OSFS returns an FS for the tree of files rooted at the directory dir on
+// the operating system's file system, like os.DirFS but writable.
+func OSFS(dir string) FS {
+	return osFS(dir)
+}
+
+func (dir osFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(dir), filepath.FromSlash(name)), nil
+}
+
+func (dir osFS) Open(name string) (fs.File, error) {
+	p, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (dir osFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := dir.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (dir osFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	p, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, flag, perm)
+}
+
+func (dir osFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := dir.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, perm)
+}
+
+func (dir osFS) Remove(name string) error {
+	p, err := dir.join("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+// CreateIfNotExistsFS is like CreateIfNotExists but operates on fsys.
+func CreateIfNotExistsFS(fsys FS, name string, isDir bool, perm ...fs.FileMode) error {
+	mode := getFileMode(perm...)
+	if _, err := fsys.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if isDir {
+		return fsys.MkdirAll(name, mode)
+	}
+	if err := fsys.MkdirAll(path.Dir(name), mode); err != nil {
+		return err
+	}
+	f, err := fsys.OpenFile(name, os.O_CREATE, mode)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// CopyFileFS is like CopyFile but reads src from srcFS, which may be any
+// fs.FS such as an embed.FS, and writes dst to dstFS.
+func CopyFileFS(srcFS fs.FS, src string, dstFS FS, dst string) (int64, error) {
+	if src == dst && sameFS(srcFS, dstFS) {
+		return 0, nil
+	}
+	sf, err := srcFS.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer sf.Close()
+	if err = dstFS.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	df, err := dstFS.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(df, sf)
+	if cerr := df.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+func sameFS(a fs.FS, b FS) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	return ta == tb && ta.Comparable() && a == fs.FS(b)
+}
+
+// ReadLineFuncFS is like ReadLineFunc but reads the named file from fsys.
+func ReadLineFuncFS(fsys fs.FS, name string, f func(num int, line string) error) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	return bufio.ReadLineFunc(file, f)
+}
+
+// ReadLineBytesFuncFS is like ReadLineBytesFunc but reads the named file from fsys.
+func ReadLineBytesFuncFS(fsys fs.FS, name string, f func(num int, line []byte) error) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	return bufio.ReadLineBytesFunc(file, f)
+}
+
+// IsExistFS reports whether the named file exists in fsys.
+func IsExistFS(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
+
+// IsDirFS reports whether the named file in fsys is a directory.
+func IsDirFS(fsys fs.FS, name string) bool {
+	fi, err := fs.Stat(fsys, name)
+	return err == nil && fi.IsDir()
+}
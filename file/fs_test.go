@@ -0,0 +1,105 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestMemFS(t *testing.T) *MemFS {
+	t.Helper()
+	m := NewMemFS()
+	for name, content := range map[string]string{
+		"a.txt":        "line 1\nline 2\n",
+		"dir/b.txt":    "b",
+		"dir/sub/c.go": "package c",
+	} {
+		if err := CreateIfNotExistsFS(m, name, false, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := m.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.WriteString(f, content); err != nil {
+			t.Fatal(err)
+		}
+		if err = f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestMemFS(t *testing.T) {
+	m := newTestMemFS(t)
+	if err := fstest.TestFS(m, "a.txt", "dir/b.txt", "dir/sub/c.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("dir"); err == nil {
+		t.Errorf("Remove() of a non-empty directory should fail")
+	}
+	if _, err := m.OpenFile("a.txt", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("OpenFile(O_EXCL) = %v, want %v", err, fs.ErrExist)
+	}
+	if err := m.MkdirAll("a.txt/x", 0o755); err == nil {
+		t.Errorf("MkdirAll() through a file should fail")
+	}
+}
+
+func TestFSHelpers(t *testing.T) {
+	m := newTestMemFS(t)
+	if !IsExistFS(m, "dir/b.txt") || IsExistFS(m, "missing") {
+		t.Errorf("IsExistFS() reported wrong results")
+	}
+	if !IsDirFS(m, "dir/sub") || IsDirFS(m, "a.txt") {
+		t.Errorf("IsDirFS() reported wrong results")
+	}
+
+	var lines []string
+	err := ReadLineFuncFS(m, "a.txt", func(num int, line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil || len(lines) != 2 || lines[1] != "line 2" {
+		t.Errorf("ReadLineFuncFS() = %q, %v", lines, err)
+	}
+
+	src := fstest.MapFS{"embedded.txt": {Data: []byte("from fs.FS")}}
+	n, err := CopyFileFS(src, "embedded.txt", m, "dir/copy.txt")
+	if err != nil || n != 10 {
+		t.Fatalf("CopyFileFS() = %d, %v", n, err)
+	}
+	b, err := fs.ReadFile(m, "dir/copy.txt")
+	if err != nil || string(b) != "from fs.FS" {
+		t.Errorf("ReadFile(copy) = %q, %v", b, err)
+	}
+	if n, err = CopyFileFS(m, "a.txt", m, "a.txt"); err != nil || n != 0 {
+		t.Errorf("CopyFileFS() onto itself = %d, %v", n, err)
+	}
+
+	if err = CreateIfNotExistsFS(m, "new/dir", true); err != nil || !IsDirFS(m, "new/dir") {
+		t.Errorf("CreateIfNotExistsFS(dir) = %v", err)
+	}
+}
+
+func TestOSFS(t *testing.T) {
+	dir := t.TempDir()
+	fsys := OSFS(dir)
+	if err := CreateIfNotExistsFS(fsys, "x/y.txt", false, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsExist(filepath.Join(dir, "x", "y.txt")) {
+		t.Errorf("CreateIfNotExistsFS() did not create the file on disk")
+	}
+	if err := fstest.TestFS(fsys, "x/y.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Open("../escape"); err == nil {
+		t.Errorf("Open() of an invalid name should fail")
+	}
+}
@@ -0,0 +1,264 @@
+package file
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"runtime"
+	"sync"
+)
+
+var errHashUnavailable = errors.New("hash function not available")
+
+// Hash returns the digest of the file at path computed with h.
+func Hash(path string, h crypto.Hash) ([]byte, error) {
+	if !h.Available() {
+		return nil, &fs.PathError{Op: "hash", Path: path, Err: errHashUnavailable}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	w := h.New()
+	if _, err = io.Copy(w, f); err != nil {
+		return nil, err
+	}
+	return w.Sum(nil), nil
+}
+
+// HashTreeOptions configures HashTree. The zero value hashes every file
+// with SHA-256, one file per CPU at a time.
+type HashTreeOptions struct {
+	// Hash is the hash function to use. It defaults to crypto.SHA256.
+	Hash crypto.Hash
+
+	// Parallel sets how many files are hashed at once.
+	Parallel int
+
+	// IgnoreFiles and Ignore exclude files as in WalkOptions.
+	IgnoreFiles []string
+	Ignore      []string
+}
+
+// ManifestEntry is a file, directory or symbolic link in a Manifest.
+type ManifestEntry struct {
+	// Path is relative to the root, separated by forward slashes.
+	Path string
+
+	// Mode holds only the type bits, so that trees compare equal
+	// regardless of permissions.
+	Mode fs.FileMode
+
+	// Size is the length of a regular file, and zero otherwise.
+	Size int64
+
+	// Digest is the hash of a file's contents or a link's target. For a
+	// directory it is the Merkle digest of its entries.
+	Digest []byte
+}
+
+// Manifest describes the contents of a directory tree. Two trees with the
+// same files, links and directories, compared by name, type and content,
+// have the same Root.
+type Manifest struct {
+	Hash crypto.Hash
+
+	// Entries is sorted by path, with directory contents directly after
+	// each directory.
+	Entries []ManifestEntry
+
+	// Root is the Merkle digest of the whole tree.
+	Root []byte
+}
+
+// Equal reports whether m and other describe the same tree.
+func (m *Manifest) Equal(other *Manifest) bool {
+	return m.Hash == other.Hash && bytes.Equal(m.Root, other.Root)
+}
+
+// ManifestDiff lists the paths that differ between two manifests.
+// Directories are only listed when added, removed or replaced by a
+// non-directory; changes to their contents are listed individually.
+type ManifestDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Diff returns how the tree described by other differs from m.
+func (m *Manifest) Diff(other *Manifest) ManifestDiff {
+	var d ManifestDiff
+	old := make(map[string]ManifestEntry, len(m.Entries))
+	for _, e := range m.Entries {
+		old[e.Path] = e
+	}
+	for _, e := range other.Entries {
+		o, ok := old[e.Path]
+		delete(old, e.Path)
+		switch {
+		case !ok:
+			d.Added = append(d.Added, e.Path)
+		case o.Mode != e.Mode:
+			d.Changed = append(d.Changed, e.Path)
+		case !e.Mode.IsDir() && !bytes.Equal(o.Digest, e.Digest):
+			d.Changed = append(d.Changed, e.Path)
+		}
+	}
+	for _, e := range m.Entries {
+		if _, ok := old[e.Path]; ok {
+			d.Removed = append(d.Removed, e.Path)
+		}
+	}
+	return d
+}
+
+// HashTree hashes every file below root in parallel and returns the
+// resulting manifest. Symbolic links are not followed; their targets are
+// hashed instead. Files other than regular files, directories and links
+// are skipped.
+func HashTree(root string, opts ...HashTreeOptions) (*Manifest, error) {
+	o := optional(opts)
+	if o.Hash == 0 {
+		o.Hash = crypto.SHA256
+	}
+	if !o.Hash.Available() {
+		return nil, &fs.PathError{Op: "hash", Path: root, Err: errHashUnavailable}
+	}
+	if o.Parallel <= 0 {
+		o.Parallel = runtime.NumCPU()
+	}
+	found, err := Walk(root, WalkOptions{IgnoreFiles: o.IgnoreFiles, Ignore: o.Ignore, Parallel: o.Parallel})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Hash: o.Hash, Entries: make([]ManifestEntry, 0, len(found))}
+	var paths []string
+	for _, w := range found {
+		t := w.Entry.Type()
+		if t&^(fs.ModeDir|fs.ModeSymlink) != 0 {
+			continue
+		}
+		m.Entries = append(m.Entries, ManifestEntry{Path: w.Rel, Mode: t})
+		paths = append(paths, w.Path)
+	}
+	if err = hashFiles(m, paths, o.Parallel); err != nil {
+		return nil, err
+	}
+
+	// Entries are sorted with each directory before its contents, so in
+	// reverse every directory comes after its entries.
+	children := make(map[string][]int)
+	for i, e := range m.Entries {
+		dir := path.Dir(e.Path)
+		children[dir] = append(children[dir], i)
+	}
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		if e := &m.Entries[i]; e.Mode.IsDir() {
+			e.Digest = m.merkle(children[e.Path])
+		}
+	}
+	m.Root = m.merkle(children["."])
+	return m, nil
+}
+
+// hashFiles fills in the digests of the files and links in m, whose paths
+// on disk are given in the same order.
+func hashFiles(m *Manifest, paths []string, parallel int) error {
+	next := make(chan int)
+	errs := make(chan error, parallel)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for n := 0; n < parallel; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := hashEntry(&m.Entries[i], paths[i], m.Hash); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var err error
+feed:
+	for i, e := range m.Entries {
+		if e.Mode.IsDir() {
+			continue
+		}
+		select {
+		case next <- i:
+		case err = <-errs:
+			break feed
+		}
+	}
+	close(next)
+	<-done
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	return err
+}
+
+func hashEntry(e *ManifestEntry, p string, h crypto.Hash) error {
+	if e.Mode&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		w := h.New()
+		io.WriteString(w, target)
+		e.Digest = w.Sum(nil)
+		return nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := h.New()
+	n, err := io.Copy(w, f)
+	if err != nil {
+		return err
+	}
+	e.Size, e.Digest = n, w.Sum(nil)
+	return nil
+}
+
+// merkle returns the digest of a directory holding the given entries, which
+// covers the name, type and digest of each.
+func (m *Manifest) merkle(entries []int) []byte {
+	w := m.Hash.New()
+	for _, i := range entries {
+		e := m.Entries[i]
+		kind := 'f'
+		switch {
+		case e.Mode.IsDir():
+			kind = 'd'
+		case e.Mode&fs.ModeSymlink != 0:
+			kind = 'l'
+		}
+		fmt.Fprintf(w, "%c %s\x00", kind, path.Base(e.Path))
+		w.Write(e.Digest)
+	}
+	return w.Sum(nil)
+}
@@ -0,0 +1,90 @@
+package file
+
+import (
+	"crypto"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := Hash(path, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got := hex.EncodeToString(sum); got != want {
+		t.Errorf("Hash() = %s, want %s", got, want)
+	}
+	if _, err = Hash(path, crypto.BLAKE2b_256); err == nil {
+		t.Errorf("Hash() with an unlinked hash function should fail")
+	}
+}
+
+func TestHashTree(t *testing.T) {
+	files := map[string]string{
+		"a.txt":       "a",
+		"sub/b.txt":   "b",
+		"sub/c/d.txt": "d",
+		"skip.log":    "ignored",
+	}
+	src, dst := t.TempDir(), t.TempDir()
+	writeTree(t, src, files)
+	writeTree(t, dst, files)
+	if err := os.Chmod(filepath.Join(dst, "a.txt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts := HashTreeOptions{Ignore: []string{"*.log"}, Parallel: 2}
+
+	m1, err := HashTree(src, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := HashTree(dst, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m1.Equal(m2) {
+		t.Fatalf("identical trees have different roots")
+	}
+	var paths []string
+	for _, e := range m1.Entries {
+		paths = append(paths, e.Path)
+	}
+	if want := []string{"a.txt", "sub", "sub/b.txt", "sub/c", "sub/c/d.txt"}; !slices.Equal(paths, want) {
+		t.Errorf("entries = %q, want %q", paths, want)
+	}
+
+	writeTree(t, dst, map[string]string{"sub/c/d.txt": "changed", "new.txt": "new"})
+	if err = os.Remove(filepath.Join(dst, "sub/b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err = os.Symlink("a.txt", filepath.Join(dst, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m2, err = HashTree(dst, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m1.Equal(m2) {
+		t.Fatalf("changed trees have the same root")
+	}
+	d := m1.Diff(m2)
+	added := []string{"new.txt"}
+	if runtime.GOOS != "windows" {
+		added = []string{"link", "new.txt"}
+	}
+	if !slices.Equal(d.Added, added) || !slices.Equal(d.Removed, []string{"sub/b.txt"}) ||
+		!slices.Equal(d.Changed, []string{"sub/c/d.txt"}) {
+		t.Errorf("Diff() = %+v", d)
+	}
+}
@@ -0,0 +1,178 @@
+package file
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// IgnoreMatcher matches slash-separated paths against .gitignore-style
+// patterns. Later patterns take precedence over earlier ones, so a negated
+// pattern ("!pattern") re-includes paths excluded before it.
+//
+// The zero value matches nothing.
+type IgnoreMatcher struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	base    string
+	negate  bool
+	dirOnly bool
+}
+
+// NewIgnoreMatcher returns a matcher for the given patterns, interpreted as
+// the lines of a .gitignore file at the root. Blank lines and comments are
+// skipped.
+func NewIgnoreMatcher(patterns ...string) *IgnoreMatcher {
+	m := &IgnoreMatcher{}
+	m.add("", patterns)
+	return m
+}
+
+// with returns a matcher with the patterns of m followed by lines, which
+// are relative to the directory base.
+func (m *IgnoreMatcher) with(base string, lines []string) *IgnoreMatcher {
+	n := &IgnoreMatcher{patterns: m.patterns[:len(m.patterns):len(m.patterns)]}
+	n.add(base, lines)
+	return n
+}
+
+func (m *IgnoreMatcher) add(base string, lines []string) {
+	if base == "." {
+		base = ""
+	}
+	for _, line := range lines {
+		if p, ok := parseIgnorePattern(line); ok {
+			p.base = base
+			m.patterns = append(m.patterns, p)
+		}
+	}
+}
+
+// Match reports whether the path, relative to the root of the patterns, is
+// ignored. As in git, a path inside an ignored directory is ignored and
+// cannot be re-included.
+func (m *IgnoreMatcher) Match(name string, isDir bool) bool {
+	name = strings.Trim(path.Clean(name), "/")
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' && m.match(name[:i], true) {
+			return true
+		}
+	}
+	return m.match(name, isDir)
+}
+
+// match reports whether name itself is ignored, without considering its
+// parent directories.
+func (m *IgnoreMatcher) match(name string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir || ignored != p.negate {
+			continue
+		}
+		rel := name
+		if p.base != "" {
+			if !strings.HasPrefix(name, p.base+"/") {
+				continue
+			}
+			rel = name[len(p.base)+1:]
+		}
+		if p.re.MatchString(rel) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	line = trimIgnoreSpace(line)
+	if line == "" || line[0] == '#' {
+		return ignorePattern{}, false
+	}
+	var p ignorePattern
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	globToRegexp(&b, line)
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return ignorePattern{}, false
+	}
+	p.re = re
+	return p, true
+}
+
+// trimIgnoreSpace removes trailing spaces unless they are escaped.
+func trimIgnoreSpace(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		if end > 1 && line[end-2] == '\\' {
+			break
+		}
+		end--
+	}
+	return line[:end]
+}
+
+// globToRegexp translates a gitignore glob into regular expression syntax.
+func globToRegexp(b *strings.Builder, glob string) {
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**") && (i == 0 || glob[i-1] == '/'):
+			switch {
+			case i+2 == len(glob):
+				b.WriteString(".*")
+				i++
+			case glob[i+2] == '/':
+				b.WriteString("(?:.*/)?")
+				i += 2
+			default:
+				b.WriteString("[^/]*")
+			}
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			b.WriteByte('[')
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				b.WriteByte('^')
+				class = class[1:]
+			}
+			b.WriteString(strings.ReplaceAll(class, `\`, `\\`))
+			b.WriteByte(']')
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package file
+
+import "testing"
+
+func TestIgnoreMatcher(t *testing.T) {
+	m := NewIgnoreMatcher(
+		"# comment",
+		"*.log",
+		"!keep.log",
+		"build/",
+		"/top.txt",
+		"docs/**/*.md",
+		"**/tmp",
+		"cache/**",
+		"a?c[0-9]",
+		`\#hash`,
+	)
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"sub/dir/app.log", false, true},
+		{"keep.log", false, false},
+		{"sub/keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"build/out.bin", false, true},
+		{"top.txt", false, true},
+		{"sub/top.txt", false, false},
+		{"docs/a.md", false, true},
+		{"docs/x/y/a.md", false, true},
+		{"other/docs/a.md", false, false},
+		{"x/tmp", true, true},
+		{"tmp", false, true},
+		{"cache", true, false},
+		{"cache/a/b", false, true},
+		{"abc1", false, true},
+		{"abcd", false, false},
+		{"#hash", false, true},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %t) = %t, want %t", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
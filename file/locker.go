@@ -1,14 +1,53 @@
 package file
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io/fs"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/nexuer/utils/sets"
 )
 
 var (
 	errAlreadyLocked = errors.New("file already locked")
+	errNotLocked     = errors.New("file not locked")
+)
+
+const (
+	minLockRetryDelay = time.Millisecond
+	maxLockRetryDelay = 100 * time.Millisecond
+)
+
+// LockType is the kind of advisory lock held on a file or a byte range.
+type LockType int8
+
+const (
+	// LockNone means no lock is held.
+	LockNone LockType = iota
+	// LockRead is a shared lock; any number of readers may hold it at once.
+	LockRead
+	// LockWrite is an exclusive lock.
+	LockWrite
 )
 
+// String returns the lowercase name of t.
+func (t LockType) String() string {
+	switch t {
+	case LockRead:
+		return "read"
+	case LockWrite:
+		return "write"
+	default:
+		return "none"
+	}
+}
+
 type Locker interface {
 	// Name returns the name of the file.
 	Name() string
@@ -46,13 +85,13 @@ func (lt lockType) String() string {
 // If Lock returns nil, no other process will be able to place a read or write
 // lock on the file until this process exits, closes f, or calls Unlock on it.
 //
-// If f's descriptor is already read- or write-locked, the behavior of Lock is
-// unspecified.
+// If f is already write-locked, Lock does nothing. If f is read-locked, the
+// lock is converted to a write lock as by Upgrade.
 //
 // Closing the file may or may not release the lock promptly. Callers should
 // ensure that Unlock is always called when Lock succeeds.
 func Lock(f Locker, immediately bool) error {
-	return lock(f, writeLock, immediately)
+	return setLock(f, LockWrite, immediately)
 }
 
 // RLock places an advisory read lock on the file, When the immediately is false, it will block
@@ -61,17 +100,295 @@ func Lock(f Locker, immediately bool) error {
 // If RLock returns nil, no other process will be able to place a write lock on
 // the file until this process exits, closes f, or calls Unlock on it.
 //
-// If f is already read- or write-locked, the behavior of RLock is unspecified.
+// If f is already read-locked, RLock does nothing. If f is write-locked, the
+// lock is converted to a read lock as by Downgrade.
 //
 // Closing the file may or may not release the lock promptly. Callers should
 // ensure that Unlock is always called if RLock succeeds.
 func RLock(f Locker, immediately bool) error {
-	return lock(f, readLock, immediately)
+	return setLock(f, LockRead, immediately)
 }
 
 // Unlock removes an advisory lock placed on f by this process.
 //
 // The caller must not attempt to unlock a file that is not locked.
 func Unlock(f Locker) error {
-	return unlock(f)
+	if err := unlock(f); err != nil {
+		return err
+	}
+	lockStates.set(f, LockNone)
+	return nil
+}
+
+// Upgrade converts the read lock held on f into a write lock. When the
+// immediately is false, it will block until the write lock can be placed,
+// otherwise an error will be returned immediately. Upgrading a write-locked
+// file does nothing, and upgrading an unlocked file is an error.
+//
+// The conversion is not atomic: another process may acquire the lock between
+// the release of the read lock and the placement of the write lock. If the
+// conversion fails, f is left unlocked and LockState reports LockNone.
+func Upgrade(f Locker, immediately bool) error {
+	if lt, tracked := lockStates.get(f); tracked && lt == LockNone {
+		return &fs.PathError{Op: "Upgrade", Path: f.Name(), Err: errNotLocked}
+	}
+	return setLock(f, LockWrite, immediately)
+}
+
+// Downgrade converts the write lock held on f into a read lock, letting other
+// readers in. Downgrading a read-locked file does nothing, and downgrading an
+// unlocked file is an error.
+//
+// The conversion is not atomic: another process may acquire the lock between
+// the release of the write lock and the placement of the read lock. If the
+// conversion fails, f is left unlocked and LockState reports LockNone.
+func Downgrade(f Locker) error {
+	if lt, tracked := lockStates.get(f); tracked && lt == LockNone {
+		return &fs.PathError{Op: "Downgrade", Path: f.Name(), Err: errNotLocked}
+	}
+	return setLock(f, LockRead, false)
+}
+
+// LockState reports the whole-file lock held on f through Lock, RLock,
+// Upgrade or Downgrade in this process. Locks placed by other processes or
+// by other descriptors of the same file are not reported.
+//
+// The state is only tracked for an *os.File, and only until it is closed;
+// LockState reports LockNone for any other Locker and for a closed file.
+// Without tracking, Lock and RLock always ask the operating system, and
+// Upgrade and Downgrade do not check that f is locked. While a file is
+// locked the package sets a finalizer on it, so callers must not set their
+// own with runtime.SetFinalizer.
+func LockState(f Locker) LockType {
+	lt, _ := lockStates.get(f)
+	return lt
+}
+
+func setLock(f Locker, lt LockType, immediately bool) error {
+	cur, tracked := lockStates.get(f)
+	if tracked && cur == lt {
+		return nil
+	}
+	if cur != LockNone && !lockConverts {
+		if err := Unlock(f); err != nil {
+			return err
+		}
+	}
+	if err := lock(f, lt.lockType(), immediately); err != nil {
+		if cur != LockNone && lockConverts {
+			_ = Unlock(f)
+		}
+		return err
+	}
+	lockStates.set(f, lt)
+	return nil
+}
+
+// lockType returns the platform lock type for t.
+func (t LockType) lockType() lockType {
+	if t == LockRead {
+		return readLock
+	}
+	return writeLock
+}
+
+// lockStateTable tracks the whole-file lock held by each *os.File, since the
+// operating system cannot be asked which lock a descriptor holds.
+//
+// Files are keyed by address rather than by pointer, so that the table does
+// not keep a file the caller dropped without closing alive: a finalizer set
+// while the file has an entry removes it once the file is collected, before
+// the address can be reused. An fd and name pair would not do as a key,
+// since it could be reused by an unrelated file once the original is closed.
+type lockStateTable struct {
+	mu sync.Mutex
+	m  map[uintptr]LockType
+}
+
+var lockStates = &lockStateTable{m: make(map[uintptr]LockType)}
+
+// trackedFile returns f as an *os.File if its lock state can be tracked.
+func trackedFile(f Locker) (*os.File, bool) {
+	of, ok := f.(*os.File)
+	return of, ok && of != nil
+}
+
+func fileKey(f *os.File) uintptr {
+	return uintptr(unsafe.Pointer(f))
+}
+
+// get returns the lock recorded for f and whether f is tracked at all.
+func (t *lockStateTable) get(f Locker) (LockType, bool) {
+	of, ok := trackedFile(f)
+	if !ok {
+		return LockNone, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lt, ok := t.m[fileKey(of)]
+	if ok && fileClosed(of) {
+		// Closed without Unlock, which releases the lock.
+		t.remove(of)
+		return LockNone, true
+	}
+	return lt, true
+}
+
+func (t *lockStateTable) set(f Locker, lt LockType) {
+	of, ok := trackedFile(f)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := fileKey(of)
+	if _, ok := t.m[k]; ok {
+		if lt == LockNone {
+			t.remove(of)
+		} else {
+			t.m[k] = lt
+		}
+		return
+	}
+	if lt != LockNone {
+		t.m[k] = lt
+		runtime.SetFinalizer(of, t.forget)
+	}
+}
+
+// remove drops the entry of f; t.mu must be held.
+func (t *lockStateTable) remove(f *os.File) {
+	delete(t.m, fileKey(f))
+	runtime.SetFinalizer(f, nil)
+}
+
+// forget is the finalizer of a tracked file that became unreachable.
+func (t *lockStateTable) forget(f *os.File) {
+	t.mu.Lock()
+	delete(t.m, fileKey(f))
+	t.mu.Unlock()
+}
+
+// fileClosed reports whether f is closed. Fd is not used because it
+// switches f to blocking mode.
+func fileClosed(f *os.File) bool {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return true
+	}
+	return rc.Control(func(uintptr) {}) != nil
+}
+
+// LockRange places an advisory lock of type lt on length bytes of the file
+// starting at offset. A length of zero extends the range to the end of the
+// file, however large it grows. When the immediately is false, it will block
+// until the range can be locked, otherwise an error will be returned
+// immediately.
+//
+// On Linux the lock is an open file description lock (F_OFD_SETLK), owned by
+// the open file rather than the process, so two descriptors opened
+// separately conflict even within one process. On kernels without OFD locks
+// and on other Unix systems a POSIX fcntl lock is used instead, which is
+// owned by the process. On Windows the range is locked with LockFileEx.
+//
+// Range locks are independent of the whole-file locks placed by Lock and
+// RLock on Unix, but share one lock table on Windows.
+func LockRange(f Locker, offset, length int64, lt LockType, immediately bool) error {
+	if lt != LockRead && lt != LockWrite {
+		return &fs.PathError{
+			Op:   "LockRange",
+			Path: f.Name(),
+			Err:  fs.ErrInvalid,
+		}
+	}
+	return lockRange(f, lt, offset, length, immediately)
+}
+
+// UnlockRange removes an advisory lock placed on the byte range by LockRange.
+// The offset and length must match those of the lock being removed.
+func UnlockRange(f Locker, offset, length int64) error {
+	return lockRange(f, LockNone, offset, length, false)
+}
+
+// WithLock opens the file at path, creating it with perm if needed, places
+// a write lock on it and calls fn with the locked file. The file is always
+// unlocked and closed afterwards, even if fn panics; errors from fn, Unlock
+// and Close are joined.
+func WithLock(path string, perm fs.FileMode, fn func(f *os.File) error) error {
+	return withLock(path, perm, LockWrite, fn)
+}
+
+// WithRLock is like WithLock but places a read lock. The file is opened
+// read-only, so a file the caller can only read can be locked too.
+func WithRLock(path string, perm fs.FileMode, fn func(f *os.File) error) error {
+	return withLock(path, perm, LockRead, fn)
+}
+
+func withLock(path string, perm fs.FileMode, lt LockType, fn func(f *os.File) error) (err error) {
+	flag := os.O_RDWR
+	if lt == LockRead {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(path, flag|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	if err = setLock(f, lt, false); err != nil {
+		return sets.NewErrors([]error{err, f.Close()})
+	}
+	defer func() {
+		err = sets.NewErrors([]error{err, Unlock(f), f.Close()})
+	}()
+	return fn(f)
+}
+
+// LockContext places an advisory write lock on the file like Lock, retrying
+// non-blocking attempts with exponential backoff until the lock is acquired
+// or ctx is done.
+//
+// If ctx ends first, the returned error wraps ctx.Err() and IsAlreadyLocked
+// reports true for it. Callers that want to report progress can try
+// Lock(f, true) first and print a message before falling back to LockContext.
+func LockContext(ctx context.Context, f Locker) error {
+	return lockContext(ctx, f, LockWrite)
+}
+
+// RLockContext places an advisory read lock on the file like RLock, retrying
+// non-blocking attempts with exponential backoff until the lock is acquired
+// or ctx is done.
+//
+// If ctx ends first, the returned error wraps ctx.Err() and IsAlreadyLocked
+// reports true for it.
+func RLockContext(ctx context.Context, f Locker) error {
+	return lockContext(ctx, f, LockRead)
+}
+
+func lockContext(ctx context.Context, f Locker, lt LockType) error {
+	return retryLocked(ctx, lt.lockType().String(), f.Name(), func() error {
+		return setLock(f, lt, true)
+	})
+}
+
+// retryLocked calls try with exponential backoff for as long as it reports
+// that the lock is held elsewhere and ctx is not done.
+func retryLocked(ctx context.Context, op, path string, try func() error) error {
+	delay := minLockRetryDelay
+	for {
+		err := try()
+		if !IsAlreadyLocked(err) {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &fs.PathError{
+				Op:   op,
+				Path: path,
+				Err:  fmt.Errorf("%w: %w", errAlreadyLocked, ctx.Err()),
+			}
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxLockRetryDelay)
+	}
 }
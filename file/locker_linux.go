@@ -0,0 +1,19 @@
+//go:build linux
+
+package file
+
+import "golang.org/x/sys/unix"
+
+// fcntlLock applies flk as an open file description lock, falling back to a
+// classic POSIX record lock on kernels older than 3.15.
+func fcntlLock(fd int, flk *unix.Flock_t, wait bool) error {
+	cmd := unix.F_OFD_SETLK
+	if wait {
+		cmd = unix.F_OFD_SETLKW
+	}
+	err := fcntlRetry(fd, cmd, flk)
+	if err == unix.EINVAL {
+		return posixLock(fd, flk, wait)
+	}
+	return err
+}
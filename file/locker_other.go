@@ -14,7 +14,10 @@ const (
 	writeLock
 )
 
-func lock(f File, lt lockType, immediately bool) error {
+// lockConverts is irrelevant where locking is unsupported.
+const lockConverts = false
+
+func lock(f Locker, lt lockType, immediately bool) error {
 	return &fs.PathError{
 		Op:   lt.String(),
 		Path: f.Name(),
@@ -22,10 +25,22 @@ func lock(f File, lt lockType, immediately bool) error {
 	}
 }
 
-func unlock(f File) error {
+func unlock(f Locker) error {
 	return &fs.PathError{
 		Op:   "Unlock",
 		Path: f.Name(),
 		Err:  errors.ErrUnsupported,
 	}
 }
+
+func lockRange(f Locker, lt LockType, offset, length int64, immediately bool) error {
+	op := "LockRange"
+	if lt == LockNone {
+		op = "UnlockRange"
+	}
+	return &fs.PathError{
+		Op:   op,
+		Path: f.Name(),
+		Err:  errors.ErrUnsupported,
+	}
+}
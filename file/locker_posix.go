@@ -0,0 +1,10 @@
+//go:build unix && !linux
+
+package file
+
+import "golang.org/x/sys/unix"
+
+// fcntlLock applies flk as a POSIX record lock.
+func fcntlLock(fd int, flk *unix.Flock_t, wait bool) error {
+	return posixLock(fd, flk, wait)
+}
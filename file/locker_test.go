@@ -1,10 +1,15 @@
 package file
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestLock(t *testing.T) {
@@ -53,3 +58,307 @@ func TestRLock(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestLockContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+	holder, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Close()
+	waiter, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waiter.Close()
+
+	if err = Lock(holder, true); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = RLockContext(ctx, waiter)
+	if !IsAlreadyLocked(err) {
+		t.Errorf("RLockContext() = %v, want already locked", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RLockContext() = %v, want context.DeadlineExceeded", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { _ = Unlock(holder) })
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = LockContext(ctx, waiter); err != nil {
+		t.Fatalf("LockContext() = %v, want nil", err)
+	}
+	if err = Unlock(waiter); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockRange(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		t.Skip("range locks only conflict between descriptors of one process with OFD or Windows locks")
+	}
+	path := filepath.Join(t.TempDir(), "records.db")
+	f1, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f1.Close()
+	f2, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f2.Close()
+
+	if err = LockRange(f1, 0, 10, LockWrite, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = LockRange(f2, 5, 10, LockRead, true); !IsAlreadyLocked(err) {
+		t.Errorf("LockRange(overlapping) = %v, want already locked", err)
+	}
+	if err = LockRange(f2, 10, 10, LockWrite, true); err != nil {
+		t.Errorf("LockRange(disjoint) = %v, want nil", err)
+	}
+	if err = UnlockRange(f1, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err = LockRange(f2, 0, 10, LockRead, true); err != nil {
+		t.Errorf("LockRange(after unlock) = %v, want nil", err)
+	}
+	if err = LockRange(f2, 0, 10, LockNone, true); err == nil {
+		t.Errorf("LockRange(LockNone) should fail")
+	}
+}
+
+func TestLockState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	other, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	if got := LockState(f); got != LockNone {
+		t.Errorf("LockState() = %v, want %v", got, LockNone)
+	}
+	if err = Upgrade(f, true); err == nil {
+		t.Errorf("Upgrade() on an unlocked file should fail")
+	}
+	if err = RLock(f, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = RLock(f, true); err != nil {
+		t.Errorf("RLock() on a read-locked file = %v, want nil", err)
+	}
+	if got := LockState(f); got != LockRead {
+		t.Errorf("LockState() = %v, want %v", got, LockRead)
+	}
+	if err = Upgrade(f, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := LockState(f); got != LockWrite {
+		t.Errorf("LockState() = %v, want %v", got, LockWrite)
+	}
+	if err = RLock(other, true); !IsAlreadyLocked(err) {
+		t.Errorf("RLock(other) while write-locked = %v, want already locked", err)
+	}
+	if err = Downgrade(f); err != nil {
+		t.Fatal(err)
+	}
+	if got := LockState(f); got != LockRead {
+		t.Errorf("LockState() = %v, want %v", got, LockRead)
+	}
+	if err = RLock(other, true); err != nil {
+		t.Errorf("RLock(other) while read-locked = %v, want nil", err)
+	}
+	if err = Upgrade(f, true); !IsAlreadyLocked(err) {
+		t.Errorf("Upgrade() with another reader = %v, want already locked", err)
+	}
+	if got := LockState(f); got != LockNone {
+		t.Errorf("LockState() after failed Upgrade = %v, want %v", got, LockNone)
+	}
+	if err = Unlock(other); err != nil {
+		t.Fatal(err)
+	}
+	if got := LockState(other); got != LockNone {
+		t.Errorf("LockState() after Unlock = %v, want %v", got, LockNone)
+	}
+}
+
+// wrappedFile is a Locker other than *os.File, whose state is not tracked.
+type wrappedFile struct {
+	*os.File
+}
+
+func TestLockStateClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Lock(f, true); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if got := LockState(f); got != LockNone {
+		t.Errorf("LockState() after Close = %v, want %v", got, LockNone)
+	}
+	g, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if err = RLock(g, true); err != nil {
+		t.Fatal(err)
+	}
+	lockStates.mu.Lock()
+	_, stale := lockStates.m[fileKey(f)]
+	lockStates.mu.Unlock()
+	if stale {
+		t.Errorf("state of the closed file is still tracked")
+	}
+	if err = Unlock(g); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new descriptor that reuses the number and name of a file closed
+	// while locked must not inherit its state.
+	holder, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Close()
+	u1, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Lock(wrappedFile{File: u1}, true); err != nil {
+		t.Fatal(err)
+	}
+	u1.Close()
+	if err = Lock(holder, true); err != nil {
+		t.Fatal(err)
+	}
+	u2, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u2.Close()
+	if err = Lock(wrappedFile{File: u2}, true); !IsAlreadyLocked(err) {
+		t.Errorf("Lock() while another descriptor holds the lock = %v, want already locked", err)
+	}
+}
+
+func TestLockStateDropped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dropped.lock")
+	key := func() uintptr {
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = Lock(f, true); err != nil {
+			t.Fatal(err)
+		}
+		return fileKey(f)
+	}()
+
+	// The table must not keep the dropped file alive, so that it is
+	// collected and its descriptor closed.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runtime.GC()
+		lockStates.mu.Lock()
+		_, tracked := lockStates.m[key]
+		lockStates.mu.Unlock()
+		if !tracked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dropped file is still tracked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		runtime.GC()
+		if err = Lock(f, true); err == nil || !IsAlreadyLocked(err) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Errorf("Lock() after the locked file was collected = %v, want nil", err)
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	errFn := errors.New("fn failed")
+	err := WithLock(path, 0o600, func(f *os.File) error {
+		if LockState(f) != LockWrite {
+			t.Errorf("LockState() = %v, want %v", LockState(f), LockWrite)
+		}
+		_, err := f.WriteString("1")
+		if err != nil {
+			return err
+		}
+		return errFn
+	})
+	if !errors.Is(err, errFn) {
+		t.Errorf("WithLock() = %v, want %v", err, errFn)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("panic was not propagated")
+			}
+		}()
+		_ = WithRLock(path, 0o600, func(f *os.File) error {
+			panic("boom")
+		})
+	}()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = Lock(f, true); err != nil {
+		t.Errorf("Lock() after WithRLock panicked = %v, want nil", err)
+	}
+	if err = Unlock(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithRLockReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("x"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	err := WithRLock(path, 0o444, func(f *os.File) error {
+		if LockState(f) != LockRead {
+			t.Errorf("LockState() = %v, want %v", LockState(f), LockRead)
+		}
+		if _, err := f.WriteString("y"); err == nil {
+			t.Errorf("WriteString() on a read-locked file = nil, want an error")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("WithRLock() on a read-only file = %v, want nil", err)
+	}
+}
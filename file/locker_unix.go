@@ -3,6 +3,7 @@
 package file
 
 import (
+	"io"
 	"io/fs"
 
 	"golang.org/x/sys/unix"
@@ -15,6 +16,10 @@ const (
 	writeLock lockType = unix.LOCK_EX
 )
 
+// lockConverts reports true because flock replaces the lock held on a
+// descriptor with the newly requested one.
+const lockConverts = true
+
 func lock(f Locker, lt lockType, immediately bool) error {
 	flags := lt
 	if immediately {
@@ -39,3 +44,50 @@ func lock(f Locker, lt lockType, immediately bool) error {
 func unlock(f Locker) error {
 	return lock(f, unix.LOCK_UN, false)
 }
+
+func lockRange(f Locker, lt LockType, offset, length int64, immediately bool) error {
+	flk := unix.Flock_t{
+		Whence: io.SeekStart,
+		Start:  offset,
+		Len:    length,
+	}
+	op := "LockRange"
+	switch lt {
+	case LockRead:
+		flk.Type = unix.F_RDLCK
+	case LockWrite:
+		flk.Type = unix.F_WRLCK
+	default:
+		flk.Type = unix.F_UNLCK
+		op = "UnlockRange"
+	}
+
+	if err := fcntlLock(int(f.Fd()), &flk, !immediately); err != nil {
+		if err == unix.EAGAIN || err == unix.EACCES {
+			err = errAlreadyLocked
+		}
+		return &fs.PathError{
+			Op:   op,
+			Path: f.Name(),
+			Err:  err,
+		}
+	}
+	return nil
+}
+
+func posixLock(fd int, flk *unix.Flock_t, wait bool) error {
+	cmd := unix.F_SETLK
+	if wait {
+		cmd = unix.F_SETLKW
+	}
+	return fcntlRetry(fd, cmd, flk)
+}
+
+func fcntlRetry(fd, cmd int, flk *unix.Flock_t) error {
+	for {
+		err := unix.FcntlFlock(uintptr(fd), cmd, flk)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
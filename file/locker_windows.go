@@ -25,6 +25,10 @@ var (
 	procLockFileEx = modkernel32.NewProc("LockFileEx")
 )
 
+// lockConverts reports false because LockFileEx stacks locks on a handle
+// instead of converting them, so a held lock must be released first.
+const lockConverts = false
+
 func lock(f Locker, lt lockType, immediately bool) error {
 	// Per https://golang.org/issue/19098, “Programs currently expect the Fd
 	// method to return a handle that uses ordinary synchronous I/O.”
@@ -65,3 +69,43 @@ func unlock(f Locker) error {
 	}
 	return nil
 }
+
+func lockRange(f Locker, lt LockType, offset, length int64, immediately bool) error {
+	ol := &windows.Overlapped{
+		Offset:     uint32(offset),
+		OffsetHigh: uint32(offset >> 32),
+	}
+	// A zero length covers the rest of the file, however large it grows.
+	low, high := allBytes, allBytes
+	if length != 0 {
+		low, high = uint32(length), uint32(length>>32)
+	}
+
+	var err error
+	op := "LockRange"
+	switch lt {
+	case LockRead, LockWrite:
+		var flags uint32
+		if lt == LockWrite {
+			flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+		}
+		if immediately {
+			flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+		}
+		err = windows.LockFileEx(windows.Handle(f.Fd()), flags, reserved, low, high, ol)
+		if errno, ok := err.(windows.Errno); ok && errno == windows.ERROR_LOCK_VIOLATION {
+			err = errAlreadyLocked
+		}
+	default:
+		op = "UnlockRange"
+		err = windows.UnlockFileEx(windows.Handle(f.Fd()), reserved, low, high, ol)
+	}
+	if err != nil {
+		return &fs.PathError{
+			Op:   op,
+			Path: f.Name(),
+			Err:  err,
+		}
+	}
+	return nil
+}
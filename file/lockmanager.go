@@ -0,0 +1,198 @@
+package file
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var defaultLockManager = NewLockManager()
+
+// LockManager coordinates file locks by path within a process. Advisory
+// locks belong to the open file, so goroutines sharing one descriptor do not
+// exclude each other; LockManager pairs each path with an in-process
+// sync.RWMutex so a lock excludes both other goroutines and other processes.
+//
+// Readers of the same path share one descriptor holding the OS read lock,
+// which is released when the last reader unlocks.
+type LockManager struct {
+	mu      sync.Mutex
+	entries map[string]*lockEntry
+}
+
+type lockEntry struct {
+	rw sync.RWMutex
+
+	// refs counts holders and waiters, guarded by LockManager.mu.
+	refs int
+
+	mu      sync.Mutex
+	f       *os.File
+	readers int
+}
+
+// NewLockManager returns an empty LockManager.
+func NewLockManager() *LockManager {
+	return &LockManager{entries: make(map[string]*lockEntry)}
+}
+
+// PathLock is a lock held through a LockManager.
+type PathLock struct {
+	m    *LockManager
+	key  string
+	e    *lockEntry
+	f    *os.File
+	lt   LockType
+	once sync.Once
+}
+
+// File returns the locked file. Read locks on the same path share one file,
+// so readers should use ReadAt rather than Read and Seek.
+func (l *PathLock) File() *os.File {
+	return l.f
+}
+
+// Type returns whether l is a read or a write lock.
+func (l *PathLock) Type() LockType {
+	return l.lt
+}
+
+// Unlock releases the lock. Calling Unlock more than once is a no-op.
+func (l *PathLock) Unlock() error {
+	var err error
+	l.once.Do(func() {
+		if l.lt == LockWrite {
+			err = closeLocked(l.e.f)
+			l.e.f = nil
+			l.e.rw.Unlock()
+		} else {
+			l.e.mu.Lock()
+			l.e.readers--
+			if l.e.readers == 0 {
+				err = closeLocked(l.e.f)
+				l.e.f = nil
+			}
+			l.e.mu.Unlock()
+			l.e.rw.RUnlock()
+		}
+		l.m.release(l.key, l.e)
+	})
+	return err
+}
+
+// Lock opens or creates the file at path and places a write lock on it,
+// excluding other goroutines using m as well as other processes. When the
+// immediately is false, it will block until the lock can be locked,
+// otherwise an error will be returned immediately.
+func (m *LockManager) Lock(path string, immediately bool) (*PathLock, error) {
+	key, e, err := m.acquire(path)
+	if err != nil {
+		return nil, err
+	}
+	if immediately {
+		if !e.rw.TryLock() {
+			m.release(key, e)
+			return nil, &fs.PathError{Op: "Lock", Path: path, Err: errAlreadyLocked}
+		}
+	} else {
+		e.rw.Lock()
+	}
+	f, err := openLocked(path, LockWrite, immediately)
+	if err != nil {
+		e.rw.Unlock()
+		m.release(key, e)
+		return nil, err
+	}
+	e.f = f
+	return &PathLock{m: m, key: key, e: e, f: f, lt: LockWrite}, nil
+}
+
+// RLock opens or creates the file at path and places a read lock on it,
+// shared with other readers using m and other processes. When the
+// immediately is false, it will block until the lock can be locked,
+// otherwise an error will be returned immediately.
+func (m *LockManager) RLock(path string, immediately bool) (*PathLock, error) {
+	key, e, err := m.acquire(path)
+	if err != nil {
+		return nil, err
+	}
+	if immediately {
+		if !e.rw.TryRLock() {
+			m.release(key, e)
+			return nil, &fs.PathError{Op: "RLock", Path: path, Err: errAlreadyLocked}
+		}
+	} else {
+		e.rw.RLock()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.readers == 0 {
+		f, err := openLocked(path, LockRead, immediately)
+		if err != nil {
+			e.rw.RUnlock()
+			m.release(key, e)
+			return nil, err
+		}
+		e.f = f
+	}
+	e.readers++
+	return &PathLock{m: m, key: key, e: e, f: e.f, lt: LockRead}, nil
+}
+
+func (m *LockManager) acquire(path string) (string, *lockEntry, error) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		e = &lockEntry{}
+		m.entries[key] = e
+	}
+	e.refs++
+	return key, e, nil
+}
+
+func (m *LockManager) release(key string, e *lockEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.refs--
+	if e.refs == 0 {
+		delete(m.entries, key)
+	}
+}
+
+func openLocked(path string, lt LockType, immediately bool) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err = setLock(f, lt, immediately); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func closeLocked(f *os.File) error {
+	err := Unlock(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// LockPath places a write lock on the file at path using the process-wide
+// LockManager. See LockManager.Lock.
+func LockPath(path string, immediately bool) (*PathLock, error) {
+	return defaultLockManager.Lock(path, immediately)
+}
+
+// RLockPath places a read lock on the file at path using the process-wide
+// LockManager. See LockManager.RLock.
+func RLockPath(path string, immediately bool) (*PathLock, error) {
+	return defaultLockManager.RLock(path, immediately)
+}
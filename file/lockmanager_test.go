@@ -0,0 +1,95 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLockManagerLock(t *testing.T) {
+	m := NewLockManager()
+	path := filepath.Join(t.TempDir(), "state.lock")
+
+	var acquired atomic.Int32
+	var held []*PathLock
+	var mu sync.Mutex
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := m.Lock(path, true)
+			if err != nil {
+				if !IsAlreadyLocked(err) {
+					t.Errorf("Lock() = %v, want already locked", err)
+				}
+				return
+			}
+			acquired.Add(1)
+			mu.Lock()
+			held = append(held, l)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if n := acquired.Load(); n != 1 {
+		t.Fatalf("%d goroutines acquired the write lock, want 1", n)
+	}
+
+	other, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err = RLock(other, true); !IsAlreadyLocked(err) {
+		t.Errorf("RLock(other descriptor) = %v, want already locked", err)
+	}
+
+	if err = held[0].Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err = held[0].Unlock(); err != nil {
+		t.Errorf("second Unlock() = %v, want nil", err)
+	}
+	if len(m.entries) != 0 {
+		t.Errorf("entries not released: %v", m.entries)
+	}
+}
+
+func TestLockManagerRLock(t *testing.T) {
+	m := NewLockManager()
+	path := filepath.Join(t.TempDir(), "state.lock")
+
+	r1, err := m.RLock(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := m.RLock(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r1.File() != r2.File() {
+		t.Errorf("readers should share one file")
+	}
+	if _, err = m.Lock(path, true); !IsAlreadyLocked(err) {
+		t.Errorf("Lock() while read-locked = %v, want already locked", err)
+	}
+	if err = r1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Lock(path, true); !IsAlreadyLocked(err) {
+		t.Errorf("Lock() with one reader left = %v, want already locked", err)
+	}
+	if err = r2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := m.Lock(path, true)
+	if err != nil {
+		t.Fatalf("Lock() after readers released = %v", err)
+	}
+	if err = w.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
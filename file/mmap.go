@@ -0,0 +1,125 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+var errTooLarge = errors.New("file too large to map")
+
+// Advice is a hint about how a MappedFile will be accessed.
+type Advice int8
+
+const (
+	AdviceNormal Advice = iota
+	// AdviceSequential expects reads from start to end, so the kernel
+	// reads ahead aggressively and drops pages behind.
+	AdviceSequential
+	// AdviceRandom expects scattered reads and disables read-ahead.
+	AdviceRandom
+	// AdviceWillNeed starts reading the file in the background.
+	AdviceWillNeed
+	// AdviceDontNeed allows the kernel to drop the cached pages.
+	AdviceDontNeed
+)
+
+// MappedFile is a read-only view of a file's contents. On unix the file is
+// memory-mapped; elsewhere it is read into memory.
+type MappedFile struct {
+	path   string
+	data   []byte
+	mapped bool
+	closed bool
+}
+
+// Mmap maps the file at path into memory for reading. Writes to the file
+// by others are visible through the mapping, but the size is fixed when
+// mapping, and truncating the file makes accessing the lost pages crash
+// the program.
+func Mmap(path string) (*MappedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size != int64(int(size)) {
+		return nil, &fs.PathError{Op: "mmap", Path: path, Err: errTooLarge}
+	}
+	m := &MappedFile{path: path}
+	if size == 0 {
+		// Zero-length mappings are not allowed.
+		return m, nil
+	}
+	m.data, m.mapped, err = mmapFile(f, int(size))
+	if err != nil {
+		return nil, &fs.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	return m, nil
+}
+
+// Bytes returns the contents of the file. The slice must not be modified
+// or used after Close.
+func (m *MappedFile) Bytes() []byte {
+	return m.data
+}
+
+// Len returns the length of the file when it was mapped.
+func (m *MappedFile) Len() int {
+	return len(m.data)
+}
+
+// ReadAt implements io.ReaderAt.
+func (m *MappedFile) ReadAt(p []byte, off int64) (int, error) {
+	if m.closed {
+		return 0, &fs.PathError{Op: "read", Path: m.path, Err: fs.ErrClosed}
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: m.path, Err: fs.ErrInvalid}
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Advise tells the kernel how the mapping will be used. It does nothing
+// when the file is not memory-mapped.
+func (m *MappedFile) Advise(advice Advice) error {
+	if m.closed {
+		return &fs.PathError{Op: "madvise", Path: m.path, Err: fs.ErrClosed}
+	}
+	if !m.mapped {
+		return nil
+	}
+	if err := madvise(m.data, advice); err != nil {
+		return &fs.PathError{Op: "madvise", Path: m.path, Err: err}
+	}
+	return nil
+}
+
+// Close unmaps the file. Slices returned by Bytes become invalid.
+func (m *MappedFile) Close() error {
+	if m.closed {
+		return &fs.PathError{Op: "close", Path: m.path, Err: fs.ErrClosed}
+	}
+	data := m.data
+	m.data, m.closed = nil, true
+	if !m.mapped {
+		return nil
+	}
+	if err := munmap(data); err != nil {
+		return &fs.PathError{Op: "munmap", Path: m.path, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build !unix
+
+package file
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile reads the file into memory, since memory mapping is not
+// supported on this platform.
+func mmapFile(f *os.File, size int) ([]byte, bool, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, false, err
+	}
+	return data, false, nil
+}
+
+func munmap(data []byte) error {
+	return nil
+}
+
+func madvise(data []byte, advice Advice) error {
+	return nil
+}
@@ -0,0 +1,77 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nexuer/utils/bufio"
+)
+
+func TestMmap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index")
+	content := "first\r\nsecond\n\nlast"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Mmap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m.Bytes()) != content || m.Len() != len(content) {
+		t.Errorf("Bytes() = %q, want %q", m.Bytes(), content)
+	}
+	if err = m.Advise(AdviceRandom); err != nil {
+		t.Errorf("Advise() error = %v", err)
+	}
+
+	buf := make([]byte, 6)
+	if n, err := m.ReadAt(buf, 7); err != nil || string(buf[:n]) != "second" {
+		t.Errorf("ReadAt(7) = %q, %v", buf[:n], err)
+	}
+	if n, err := m.ReadAt(buf, int64(len(content)-4)); err != io.EOF || string(buf[:n]) != "last" {
+		t.Errorf("ReadAt(end) = %q, %v; want %q, EOF", buf[:n], err, "last")
+	}
+
+	var lines []string
+	err = bufio.ScanLineFunc(m.Bytes(), func(num int, line string) error {
+		lines = append(lines, strings.Clone(line))
+		return nil
+	})
+	if want := []string{"first", "second", "", "last"}; err != nil || !slices.Equal(lines, want) {
+		t.Errorf("ScanLineFunc() = %q, %v; want %q", lines, err, want)
+	}
+
+	if err = m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.ReadAt(buf, 0); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("ReadAt() after Close error = %v, want ErrClosed", err)
+	}
+	if err = m.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("second Close() error = %v, want ErrClosed", err)
+	}
+}
+
+func TestMmapEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Mmap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if m.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", m.Len())
+	}
+	if _, err = m.ReadAt(make([]byte, 1), 0); err != io.EOF {
+		t.Errorf("ReadAt() error = %v, want EOF", err)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package file
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func mmapFile(f *os.File, size int) ([]byte, bool, error) {
+	data, err := unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func munmap(data []byte) error {
+	return unix.Munmap(data)
+}
+
+func madvise(data []byte, advice Advice) error {
+	var a int
+	switch advice {
+	case AdviceSequential:
+		a = unix.MADV_SEQUENTIAL
+	case AdviceRandom:
+		a = unix.MADV_RANDOM
+	case AdviceWillNeed:
+		a = unix.MADV_WILLNEED
+	case AdviceDontNeed:
+		a = unix.MADV_DONTNEED
+	default:
+		a = unix.MADV_NORMAL
+	}
+	return unix.Madvise(data, a)
+}
@@ -0,0 +1,139 @@
+package file
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var errSpecialFile = errors.New("cannot copy a special file")
+
+// MoveOptions configures Move. The zero value refuses to replace an
+// existing destination.
+type MoveOptions struct {
+	// Overwrite replaces an existing dst file, as os.Rename does.
+	// Otherwise Move fails with an error wrapping fs.ErrExist. On Linux and
+	// Windows the final rename refuses to replace dst by itself; elsewhere
+	// dst is checked beforehand, so one created concurrently is replaced.
+	Overwrite bool
+
+	// PreserveOwner keeps the owner and group when Move has to copy.
+	// Permission bits and modification times are always kept.
+	PreserveOwner bool
+}
+
+// Move renames src to dst. When they are on different file systems, so
+// that renaming fails, it copies src to a temporary name next to dst,
+// renames the copy into place and removes src. A failed copy is removed
+// again, leaving dst untouched. src may be a file, a directory or a
+// symbolic link, which is moved as a link. Other special files, such as
+// pipes and devices, cannot be moved across file systems.
+//
+// If src cannot be removed after a successful copy, the error is returned
+// and both src and dst exist.
+func Move(src, dst string, opts ...MoveOptions) error {
+	o := optional(opts)
+	cleanSrc := filepath.Clean(src)
+	cleanDst := filepath.Clean(dst)
+	fi, err := os.Lstat(cleanSrc)
+	if err != nil {
+		return err
+	}
+	if !o.Overwrite {
+		if dfi, err := os.Lstat(cleanDst); err == nil && !os.SameFile(fi, dfi) {
+			return &fs.PathError{Op: "Move", Path: cleanDst, Err: fs.ErrExist}
+		}
+	}
+	err = rename(cleanSrc, cleanDst, o.Overwrite)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+	return moveByCopy(cleanSrc, cleanDst, fi, o)
+}
+
+func moveByCopy(src, dst string, fi fs.FileInfo, o MoveOptions) (err error) {
+	dir, base := filepath.Split(dst)
+	var tmp string
+	defer func() {
+		if err != nil && tmp != "" {
+			_ = os.RemoveAll(tmp)
+		}
+	}()
+
+	switch {
+	case fi.IsDir():
+		if tmp, err = os.MkdirTemp(dir, "."+base+".move-"); err != nil {
+			return err
+		}
+		err = CopyDir(src, tmp, CopyDirOptions{PreserveMode: true, PreserveTimes: true, PreserveOwner: o.PreserveOwner})
+	case fi.Mode()&fs.ModeSymlink != 0:
+		var target string
+		if target, err = os.Readlink(src); err != nil {
+			return err
+		}
+		if tmp, err = tempName(dir, "."+base+".move-"); err != nil {
+			return err
+		}
+		if err = os.Symlink(target, tmp); err == nil && o.PreserveOwner {
+			err = copyMetadata(tmp, fi, false, false, true)
+		}
+	case !fi.Mode().IsRegular():
+		// Copying would block on a pipe or read a device's contents.
+		return &fs.PathError{Op: "Move", Path: src, Err: errSpecialFile}
+	default:
+		if tmp, err = tempName(dir, "."+base+".move-"); err != nil {
+			return err
+		}
+		if _, err = CopyFile(src, tmp); err == nil {
+			err = copyMetadata(tmp, fi, true, true, o.PreserveOwner)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err = rename(tmp, dst, o.Overwrite); err != nil {
+		return err
+	}
+	tmp = ""
+	return os.RemoveAll(src)
+}
+
+// rename renames src to dst like os.Rename. Unless overwrite is set, it
+// fails with an error wrapping fs.ErrExist if dst exists and is not the same
+// file as src, atomically where the platform supports it.
+func rename(src, dst string, overwrite bool) error {
+	if overwrite {
+		return os.Rename(src, dst)
+	}
+	err := renameNoReplace(src, dst)
+	if errors.Is(err, errors.ErrUnsupported) {
+		if _, err = os.Lstat(dst); os.IsNotExist(err) {
+			return os.Rename(src, dst)
+		} else if err == nil {
+			err = fs.ErrExist
+		}
+	}
+	if errors.Is(err, fs.ErrExist) {
+		// Renaming a file onto another name of itself, such as a hard link
+		// or a different case, is allowed.
+		sfi, serr := os.Lstat(src)
+		dfi, derr := os.Lstat(dst)
+		if serr == nil && derr == nil && os.SameFile(sfi, dfi) {
+			return os.Rename(src, dst)
+		}
+		return &fs.PathError{Op: "Move", Path: dst, Err: fs.ErrExist}
+	}
+	return err
+}
+
+// tempName returns an unused name in dir starting with prefix.
+func tempName(dir, prefix string) (string, error) {
+	f, err := os.CreateTemp(dir, prefix)
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	f.Close()
+	return name, os.Remove(name)
+}
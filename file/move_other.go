@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package file
+
+// isCrossDevice reports false because rename errors cannot be told apart
+// on this platform.
+func isCrossDevice(err error) bool {
+	return false
+}
@@ -0,0 +1,140 @@
+package file
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestMove(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"src": "data", "other": "other"})
+
+	if err := Move(filepath.Join(dir, "src"), filepath.Join(dir, "other")); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Move() onto an existing file error = %v, want ErrExist", err)
+	}
+	if err := Move(filepath.Join(dir, "src"), filepath.Join(dir, "other"), MoveOptions{Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "other")); err != nil || string(b) != "data" || IsExist(filepath.Join(dir, "src")) {
+		t.Errorf("Move(Overwrite) left other = %q, %v", b, err)
+	}
+}
+
+func TestMoveNoReplace(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"src": "data", "dst": "keep"})
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+
+	// rename is also what guards against a dst created after Move checked.
+	if err := rename(src, dst, false); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("rename() onto an existing file error = %v, want ErrExist", err)
+	}
+	if b, err := os.ReadFile(dst); err != nil || string(b) != "keep" {
+		t.Errorf("dst = %q, %v; want it untouched", b, err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Link(src, link); err != nil {
+		t.Skip(err)
+	}
+	if err := rename(src, link, false); err != nil {
+		t.Errorf("rename() onto a hard link of itself = %v, want nil", err)
+	}
+}
+
+func TestMoveByCopy(t *testing.T) {
+	src, dstDir := t.TempDir(), t.TempDir()
+	writeTree(t, src, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	file := filepath.Join(src, "sub/b.txt")
+	if err := os.Chmod(file, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	// Move a single file.
+	fi, err := os.Lstat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := filepath.Join(dstDir, "b.txt")
+	if err = moveByCopy(file, moved, fi, MoveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.Stat(moved)
+	if err != nil || !got.ModTime().Equal(mtime) || runtime.GOOS != "windows" && got.Mode().Perm() != 0o600 {
+		t.Errorf("moved file = %v, %v; want mode 0600 and time %v", got.Mode(), err, mtime)
+	}
+	if IsExist(file) {
+		t.Errorf("source file still exists")
+	}
+
+	// Move a whole directory.
+	fi, err = os.Lstat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dstDir, "tree")
+	if err = moveByCopy(src, dst, fi, MoveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "a.txt")); err != nil || string(b) != "a" {
+		t.Errorf("moved a.txt = %q, %v", b, err)
+	}
+	if IsExist(src) {
+		t.Errorf("source directory still exists")
+	}
+}
+
+func TestMoveByCopyCleanup(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		t.Skip("needs permission checks")
+	}
+	src, dstDir := t.TempDir(), t.TempDir()
+	writeTree(t, src, map[string]string{"a.txt": "a", "secret": "s"})
+	if err := os.Chmod(filepath.Join(src, "secret"), 0); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Lstat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = moveByCopy(src, filepath.Join(dstDir, "tree"), fi, MoveOptions{}); err == nil {
+		t.Fatalf("moveByCopy() of an unreadable file should fail")
+	}
+	if entries, _ := os.ReadDir(dstDir); len(entries) != 0 {
+		t.Errorf("partial copy left behind: %v", entries)
+	}
+	if !IsExist(filepath.Join(src, "a.txt")) {
+		t.Errorf("source was removed after a failed copy")
+	}
+}
+
+func TestMoveByCopySpecial(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no special files")
+	}
+	sock := filepath.Join(t.TempDir(), "sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	fi, err := os.Lstat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = moveByCopy(sock, filepath.Join(t.TempDir(), "sock"), fi, MoveOptions{}); !errors.Is(err, errSpecialFile) {
+		t.Errorf("moveByCopy() of a socket = %v, want %v", err, errSpecialFile)
+	}
+	if !IsExist(sock) {
+		t.Errorf("source socket was removed")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package file
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// isCrossDevice reports whether err is a failed rename across file systems.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, unix.EXDEV)
+}
@@ -0,0 +1,188 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nexuer/utils/sets"
+)
+
+// processStart approximates the start time of the current process.
+var processStart = time.Now()
+
+// PIDInfo describes the process holding a PID file.
+type PIDInfo struct {
+	PID       int
+	Hostname  string
+	StartTime time.Time
+}
+
+// Alive reports whether the process described by i is still running. A
+// holder on another host cannot be checked and is always reported alive.
+func (i PIDInfo) Alive() bool {
+	if host, err := os.Hostname(); err == nil && i.Hostname != "" && i.Hostname != host {
+		return true
+	}
+	return processAlive(i.PID)
+}
+
+func (i PIDInfo) String() string {
+	return fmt.Sprintf("pid %d on %s since %s", i.PID, i.Hostname, i.StartTime.Format(time.RFC3339))
+}
+
+// PIDFile is a lock file guarding a single running instance. It holds a
+// write lock on the file for as long as it is held, and the file records
+// the PID, hostname and start time of the holder.
+type PIDFile struct {
+	f        *os.File
+	path     string
+	info     PIDInfo
+	previous *PIDInfo
+	locked   bool
+	released bool
+}
+
+// AcquirePIDFile creates the file at path if needed, locks it without
+// blocking and records the current process in it.
+//
+// If another live process holds the file, the returned error satisfies
+// IsAlreadyLocked and ReadPIDFile reports who the holder is. A file left
+// behind by a process that died is taken over; Previous then returns the
+// dead holder. On platforms without advisory locks the liveness of the
+// recorded process decides whether the file is held.
+func AcquirePIDFile(path string) (*PIDFile, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		p, retry, err := lockPIDFile(f, path)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if !retry {
+			return p, nil
+		}
+		f.Close()
+	}
+}
+
+func lockPIDFile(f *os.File, path string) (p *PIDFile, retry bool, err error) {
+	p = &PIDFile{f: f, path: path}
+	err = lockPID(f)
+	switch {
+	case err == nil:
+		p.locked = true
+	case errors.Is(err, errors.ErrUnsupported):
+		if info, rerr := readPIDInfo(f); rerr == nil && info.PID != os.Getpid() && info.Alive() {
+			return nil, false, &fs.PathError{Op: "Lock", Path: path, Err: errAlreadyLocked}
+		}
+	default:
+		return nil, false, err
+	}
+
+	// A previous holder releasing the file removes it before unlocking, so
+	// the lock may have been taken on a file that is no longer at path.
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, false, p.unlock(err)
+	}
+	if cur, serr := os.Stat(path); serr != nil || !os.SameFile(fi, cur) {
+		return nil, true, p.unlock(nil)
+	}
+
+	if prev, rerr := readPIDInfo(f); rerr == nil {
+		p.previous = &prev
+	}
+	host, _ := os.Hostname()
+	p.info = PIDInfo{PID: os.Getpid(), Hostname: host, StartTime: processStart}
+	data := fmt.Sprintf("%d\n%s\n%s\n", p.info.PID, p.info.Hostname, p.info.StartTime.Format(time.RFC3339Nano))
+	if err = f.Truncate(0); err == nil {
+		if _, err = f.WriteAt([]byte(data), 0); err == nil {
+			err = f.Sync()
+		}
+	}
+	if err != nil {
+		return nil, false, p.unlock(err)
+	}
+	return p, false, nil
+}
+
+func (p *PIDFile) unlock(err error) error {
+	if p.locked {
+		p.locked = false
+		return sets.NewErrors([]error{err, unlockPID(p.f)})
+	}
+	return err
+}
+
+// Path returns the path of the PID file.
+func (p *PIDFile) Path() string {
+	return p.path
+}
+
+// Info returns the holder information written by this process.
+func (p *PIDFile) Info() PIDInfo {
+	return p.info
+}
+
+// Previous returns the stale holder whose file was taken over, if any.
+func (p *PIDFile) Previous() (PIDInfo, bool) {
+	if p.previous == nil {
+		return PIDInfo{}, false
+	}
+	return *p.previous, true
+}
+
+// Release removes the PID file and releases the lock. Where open files can
+// be removed, the file is removed before it is unlocked so that no other
+// process can lock the stale copy; on Windows it is closed first.
+//
+// Release may be called more than once; later calls do nothing, so they
+// cannot remove a file acquired by another process in the meantime.
+func (p *PIDFile) Release() error {
+	if p.released {
+		return nil
+	}
+	p.released = true
+	return releasePIDFile(p)
+}
+
+// ReadPIDFile returns the holder information recorded in the PID file at path.
+func ReadPIDFile(path string) (PIDInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return PIDInfo{}, err
+	}
+	defer f.Close()
+	return readPIDInfo(f)
+}
+
+func readPIDInfo(r io.ReaderAt) (PIDInfo, error) {
+	buf := make([]byte, 512)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return PIDInfo{}, err
+	}
+	lines := strings.Split(string(bytes.TrimSpace(buf[:n])), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return PIDInfo{}, fmt.Errorf("invalid pid file: %w", err)
+	}
+	info := PIDInfo{PID: pid}
+	if len(lines) > 1 {
+		info.Hostname = strings.TrimSpace(lines[1])
+	}
+	if len(lines) > 2 {
+		info.StartTime, _ = time.Parse(time.RFC3339Nano, strings.TrimSpace(lines[2]))
+	}
+	return info, nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package file
+
+import (
+	"os"
+
+	"github.com/nexuer/utils/sets"
+)
+
+// lockPID places the lock guarding a PID file. Whole-file locks are
+// advisory here, so the holder information stays readable.
+func lockPID(f *os.File) error {
+	return Lock(f, true)
+}
+
+func unlockPID(f *os.File) error {
+	return Unlock(f)
+}
+
+func releasePIDFile(p *PIDFile) error {
+	return sets.NewErrors([]error{os.Remove(p.path), p.unlock(nil), p.f.Close()})
+}
@@ -0,0 +1,99 @@
+package file
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcquirePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	p, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Previous(); ok {
+		t.Errorf("Previous() should be empty for a new file")
+	}
+	info, err := ReadPIDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PID != os.Getpid() || !info.Alive() {
+		t.Errorf("ReadPIDFile() = %+v, want live pid %d", info, os.Getpid())
+	}
+	if _, err = AcquirePIDFile(path); !IsAlreadyLocked(err) {
+		t.Errorf("second AcquirePIDFile() = %v, want already locked", err)
+	}
+	if err = p.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if IsExist(path) {
+		t.Errorf("Release() should remove %s", path)
+	}
+}
+
+func TestAcquirePIDFileStale(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	dead := cmd.Process.Pid
+
+	path := filepath.Join(t.TempDir(), "app.pid")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(dead)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+	prev, ok := p.Previous()
+	if !ok || prev.PID != dead {
+		t.Errorf("Previous() = %+v, %t, want pid %d", prev, ok, dead)
+	}
+	if prev.Alive() {
+		t.Errorf("previous holder %d should not be alive", dead)
+	}
+}
+
+func TestPIDFileReadableWhileHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	p, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("reading a held PID file: %v", err)
+	}
+	if want := strconv.Itoa(os.Getpid()) + "\n"; !strings.HasPrefix(string(data), want) {
+		t.Errorf("PID file = %q, want it to start with %q", data, want)
+	}
+	if err = p.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A repeated Release must leave the next holder's file alone.
+	next, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer next.Release()
+	if err = p.Release(); err != nil {
+		t.Errorf("second Release() = %v, want nil", err)
+	}
+	if !IsExist(path) {
+		t.Errorf("second Release() removed the file of the next holder")
+	}
+}
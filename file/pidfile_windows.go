@@ -0,0 +1,36 @@
+//go:build windows
+
+package file
+
+import (
+	"errors"
+	"os"
+
+	"github.com/nexuer/utils/sets"
+	"golang.org/x/sys/windows"
+)
+
+// pidLockOffset is where the byte locked to guard a PID file lies, well past
+// the holder information. Windows locks are mandatory, so locking the data
+// itself would keep other processes from reading who holds the file.
+const pidLockOffset = 1 << 62
+
+func lockPID(f *os.File) error {
+	return LockRange(f, pidLockOffset, 1, LockWrite, true)
+}
+
+func unlockPID(f *os.File) error {
+	return UnlockRange(f, pidLockOffset, 1)
+}
+
+// releasePIDFile closes the file before removing it, since files opened by
+// Go cannot be removed while open. A process that opened the file in the
+// meantime to acquire it makes the removal fail, which is not an error: the
+// file is then its to use.
+func releasePIDFile(p *PIDFile) error {
+	errs := []error{p.unlock(nil), p.f.Close()}
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) && !errors.Is(err, windows.ERROR_SHARING_VIOLATION) {
+		errs = append(errs, err)
+	}
+	return sets.NewErrors(errs)
+}
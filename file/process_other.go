@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package file
+
+// processAlive cannot inspect processes on this platform and conservatively
+// reports every positive pid as alive.
+func processAlive(pid int) bool {
+	return pid > 0
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package file
+
+import "golang.org/x/sys/unix"
+
+// processAlive reports whether a process with the given pid exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
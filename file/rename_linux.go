@@ -0,0 +1,24 @@
+//go:build linux
+
+package file
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// renameNoReplace renames src to dst unless dst exists, using renameat2
+// with RENAME_NOREPLACE. It returns errors.ErrUnsupported on kernels and
+// file systems without it.
+func renameNoReplace(src, dst string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, src, unix.AT_FDCWD, dst, unix.RENAME_NOREPLACE)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.ENOSYS), errors.Is(err, unix.EINVAL):
+		return errors.ErrUnsupported
+	}
+	return &os.LinkError{Op: "rename", Old: src, New: dst, Err: err}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !windows
+
+package file
+
+import "errors"
+
+// renameNoReplace returns errors.ErrUnsupported, as there is no portable way
+// to rename without replacing the destination here.
+func renameNoReplace(src, dst string) error {
+	return errors.ErrUnsupported
+}
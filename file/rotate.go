@@ -0,0 +1,371 @@
+package file
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nexuer/utils/sets"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingWriterOptions configures a RotatingWriter. The zero value never
+// rotates on its own and keeps every backup.
+type RotatingWriterOptions struct {
+	// MaxSize rotates the file before a write would make it larger than
+	// this many bytes. Zero disables size-based rotation.
+	MaxSize int64
+
+	// Interval rotates the file when the wall clock enters a new multiple
+	// of Interval (counted in UTC from the zero time), so 24 * time.Hour
+	// rotates at midnight UTC. Zero disables time-based rotation.
+	Interval time.Duration
+
+	// MaxBackups is how many rotated files to keep. Zero keeps them all.
+	MaxBackups int
+
+	// MaxAge removes rotated files older than this. Zero keeps them all.
+	MaxAge time.Duration
+
+	// Compress gzips rotated files in the background.
+	Compress bool
+
+	// Perm is the mode for new files. It defaults to 0o644.
+	Perm fs.FileMode
+
+	// Shared coordinates with other processes writing the same file by
+	// holding a write lock on path+".lock" during each write and rotation.
+	Shared bool
+}
+
+// RotatingWriter is an io.WriteCloser that appends to a file and rotates it
+// by size and time. Rotated files are renamed to name-TIMESTAMP.ext next to
+// the original, optionally compressed, and pruned by count and age.
+//
+// A RotatingWriter is safe for concurrent use, and with Shared set several
+// processes may write to the same file.
+type RotatingWriter struct {
+	path string
+	opts RotatingWriterOptions
+
+	mu     sync.Mutex
+	closed bool
+	lock   *os.File
+	size   int64
+	period time.Time
+
+	// f is nil after a failed rotation, until it is reopened.
+	f *os.File
+
+	millMu  sync.Mutex
+	millWG  sync.WaitGroup
+	millErr error
+}
+
+// NewRotatingWriter opens or creates the file at path for appending,
+// creating its directory if needed.
+func NewRotatingWriter(path string, opts ...RotatingWriterOptions) (*RotatingWriter, error) {
+	w := &RotatingWriter{path: filepath.Clean(path), opts: optional(opts)}
+	if w.opts.Perm == 0 {
+		w.opts.Perm = 0o644
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), DefaultFileMode); err != nil {
+		return nil, err
+	}
+	if w.opts.Shared {
+		lock, err := os.OpenFile(w.path+".lock", os.O_RDWR|os.O_CREATE, w.opts.Perm)
+		if err != nil {
+			return nil, err
+		}
+		w.lock = lock
+	}
+	err := w.locked(func() error {
+		return w.open()
+	})
+	if err != nil {
+		if w.lock != nil {
+			w.lock.Close()
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends b to the file, rotating it first if needed.
+func (w *RotatingWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, &fs.PathError{Op: "write", Path: w.path, Err: fs.ErrClosed}
+	}
+	var n int
+	err := w.locked(func() error {
+		if err := w.sync(); err != nil {
+			return err
+		}
+		if w.due(int64(len(b))) {
+			if err := w.rotate(); err != nil {
+				return err
+			}
+		}
+		var err error
+		n, err = w.f.Write(b)
+		w.size += int64(n)
+		return err
+	})
+	return n, err
+}
+
+// Rotate rotates the file immediately.
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return &fs.PathError{Op: "rotate", Path: w.path, Err: fs.ErrClosed}
+	}
+	return w.locked(func() error {
+		if err := w.sync(); err != nil {
+			return err
+		}
+		return w.rotate()
+	})
+}
+
+// Close closes the file and waits for background compression and pruning
+// to finish, returning any error they ran into.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return &fs.PathError{Op: "close", Path: w.path, Err: fs.ErrClosed}
+	}
+	w.closed = true
+	var errs []error
+	if w.f != nil {
+		errs = append(errs, w.f.Close())
+		w.f = nil
+	}
+	if w.lock != nil {
+		errs = append(errs, w.lock.Close())
+	}
+	w.millWG.Wait()
+	errs = append(errs, w.millErr)
+	return sets.NewErrors(errs)
+}
+
+// locked runs fn while holding the cross-process lock in shared mode.
+func (w *RotatingWriter) locked(fn func() error) (err error) {
+	if w.lock == nil {
+		return fn()
+	}
+	if err = Lock(w.lock, false); err != nil {
+		return err
+	}
+	defer func() {
+		err = sets.NewErrors([]error{err, Unlock(w.lock)})
+	}()
+	return fn()
+}
+
+func (w *RotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, w.opts.Perm)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.size = f, fi.Size()
+	w.period = w.periodOf(fi.ModTime())
+	if fi.Size() == 0 {
+		w.period = w.periodOf(time.Now())
+	}
+	return nil
+}
+
+// sync reopens the file after a failed rotation, or if another process
+// rotated it, and refreshes the size, which other processes may have
+// changed.
+func (w *RotatingWriter) sync() error {
+	if w.f == nil {
+		return w.open()
+	}
+	if w.lock == nil {
+		return nil
+	}
+	cur, err := w.f.Stat()
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(w.path)
+	if err == nil && os.SameFile(cur, fi) {
+		w.size = cur.Size()
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	w.f.Close()
+	w.f = nil
+	return w.open()
+}
+
+func (w *RotatingWriter) due(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.MaxSize > 0 && w.size+n > w.opts.MaxSize {
+		return true
+	}
+	return w.opts.Interval > 0 && w.periodOf(time.Now()).After(w.period)
+}
+
+func (w *RotatingWriter) periodOf(t time.Time) time.Time {
+	if w.opts.Interval <= 0 {
+		return time.Time{}
+	}
+	return t.UTC().Truncate(w.opts.Interval)
+}
+
+// rotate renames the file to a backup name and opens a new one. If that
+// fails, the file at path is reopened so that writing can go on; should
+// that fail too, the next write retries.
+func (w *RotatingWriter) rotate() error {
+	// The file is closed before renaming it, which Windows requires.
+	err := w.f.Close()
+	w.f = nil
+	if err != nil {
+		return sets.NewErrors([]error{err, w.open()})
+	}
+	dir, prefix, ext := w.nameParts()
+	t := time.Now().UTC()
+	backup := filepath.Join(dir, prefix+t.Format(backupTimeFormat)+ext)
+	for IsExist(backup) || IsExist(backup+".gz") {
+		// Keep backup names unique when rotating more than once a millisecond.
+		t = t.Add(time.Millisecond)
+		backup = filepath.Join(dir, prefix+t.Format(backupTimeFormat)+ext)
+	}
+	if err = os.Rename(w.path, backup); err != nil && !os.IsNotExist(err) {
+		return sets.NewErrors([]error{err, w.open()})
+	}
+	if err = w.open(); err != nil {
+		return err
+	}
+	w.millWG.Add(1)
+	go func() {
+		defer w.millWG.Done()
+		w.millMu.Lock()
+		defer w.millMu.Unlock()
+		if err := w.mill(); err != nil && w.millErr == nil {
+			w.millErr = err
+		}
+	}()
+	return nil
+}
+
+// nameParts splits the path into its directory, the backup name prefix and
+// the extension.
+func (w *RotatingWriter) nameParts() (dir, prefix, ext string) {
+	dir, base := filepath.Split(w.path)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+type rotatedFile struct {
+	path string
+	time time.Time
+	gz   bool
+}
+
+// mill compresses and prunes rotated files.
+func (w *RotatingWriter) mill() error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	cutoff := time.Now().Add(-w.opts.MaxAge)
+	for i, b := range backups {
+		if w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups || w.opts.MaxAge > 0 && b.time.Before(cutoff) {
+			if err = os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if w.opts.Compress && !b.gz {
+			errs = append(errs, compressFile(b.path, w.opts.Perm))
+		}
+	}
+	return sets.NewErrors(errs)
+}
+
+// backups returns the rotated files, newest first.
+func (w *RotatingWriter) backups() ([]rotatedFile, error) {
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []rotatedFile
+	for _, d := range entries {
+		name := d.Name()
+		if d.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts, gz := strings.CutSuffix(name[len(prefix):], ".gz")
+		ts, ok := strings.CutSuffix(ts, ext)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, rotatedFile{path: filepath.Join(dir, name), time: t, gz: gz})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups, nil
+}
+
+// compressFile replaces path with a gzipped copy at path+".gz".
+func compressFile(path string, perm fs.FileMode) error {
+	src, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Compressed concurrently by another process.
+			return nil
+		}
+		return err
+	}
+	defer src.Close()
+	dst, err := NewAtomicWriter(path+".gz", perm)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		_ = dst.Abort()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package file
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := NewRotatingWriter(path, RotatingWriterOptions{
+		MaxSize:    22,
+		MaxBackups: 2,
+		Compress:   true,
+		Shared:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if _, err := w.Write([]byte("0123456789\n")); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > 22 {
+		t.Errorf("current file has %d bytes, want at most 22", len(b))
+	}
+	backups, err := filepath.Glob(filepath.Join(dir, "app-*.log*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("found backups %v, want 2", backups)
+	}
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup, ".log.gz") {
+			t.Errorf("backup %s is not compressed", backup)
+			continue
+		}
+		f, err := os.Open(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(gz)
+		f.Close()
+		if err != nil || string(data) != "0123456789\n0123456789\n" {
+			t.Errorf("backup %s = %q, %v", backup, data, err)
+		}
+	}
+	if _, err = w.Write([]byte("x")); err == nil {
+		t.Errorf("Write() after Close should fail")
+	}
+}
+
+func TestRotatingWriterInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := NewRotatingWriter(path, RotatingWriterOptions{Interval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err = w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err = w.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if len(backups) != 1 {
+		t.Fatalf("found backups %v, want 1", backups)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "second\n" {
+		t.Errorf("current file = %q, want %q", b, "second\n")
+	}
+}
+
+func TestRotatingWriterFailedRotate(t *testing.T) {
+	for _, shared := range []bool{false, true} {
+		testRotatingWriterFailedRotate(t, shared)
+	}
+}
+
+func testRotatingWriterFailedRotate(t *testing.T, shared bool) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "app.log")
+	w, err := NewRotatingWriter(path, RotatingWriterOptions{Compress: true, Shared: shared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// With the directory gone, neither renaming nor reopening can work.
+	if err = os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Rotate(); err == nil {
+		t.Fatalf("Rotate() without a directory should fail")
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("second\n")); err != nil {
+		t.Errorf("Write() after a failed rotation = %v, want nil", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "second\n" {
+		t.Errorf("file = %q, want %q", b, "second\n")
+	}
+	if err = w.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("second Close() = %v, want ErrClosed", err)
+	}
+}
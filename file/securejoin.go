@@ -0,0 +1,130 @@
+package file
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks limits how many symbolic links are followed while resolving a
+// single path, matching the Linux limit.
+const maxSymlinks = 40
+
+// pathSeparators holds the characters that separate path elements.
+const pathSeparators = "/" + string(filepath.Separator)
+
+var errPathEscapes = errors.New("path escapes from root")
+
+// SecureJoin joins unsafePath to root like filepath.Join, but resolves ".."
+// and symbolic links as if root were the file system root: ".." at root
+// stays at root and absolute link targets are taken relative to root. The
+// result is always root or a path below it. Components that do not exist
+// are joined as they are.
+//
+// The result is only safe to use as long as nobody can change the links
+// below root concurrently; OpenInRoot does not have that limitation on
+// Linux.
+func SecureJoin(root, unsafePath string) (string, error) {
+	return resolveInRoot(root, unsafePath, true)
+}
+
+// OpenInRoot opens the named file below root for reading. Unlike
+// SecureJoin, a path that would escape from root, through "..", an
+// absolute path or a symbolic link, is an error.
+//
+// On Linux it uses openat2 with RESOLVE_BENEATH, so that the kernel does
+// the resolution and the check cannot be raced. Elsewhere, or on kernels
+// before 5.6, the path is resolved first and opened afterwards.
+func OpenInRoot(root, name string) (*os.File, error) {
+	return OpenFileInRoot(root, name, os.O_RDONLY, 0)
+}
+
+// OpenFileInRoot is the generalized OpenInRoot, taking the same flag and
+// perm as os.OpenFile.
+func OpenFileInRoot(root, name string, flag int, perm fs.FileMode) (*os.File, error) {
+	return openInRoot(filepath.Clean(root), name, flag, perm)
+}
+
+func openInRootFallback(root, name string, flag int, perm fs.FileMode) (*os.File, error) {
+	p, err := resolveInRoot(root, name, false)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, flag, perm)
+}
+
+// resolveInRoot resolves name below root one component at a time. With
+// clamp, ".." at root and absolute paths and link targets are interpreted
+// relative to root; otherwise they are errors.
+func resolveInRoot(root, name string, clamp bool) (string, error) {
+	root = filepath.Clean(root)
+	escape := &fs.PathError{Op: "resolve", Path: name, Err: errPathEscapes}
+	rest := name
+	if isAbsPath(rest) {
+		if !clamp {
+			return "", escape
+		}
+		rest = rest[len(filepath.VolumeName(rest)):]
+	}
+	var resolved []string
+	links := 0
+	for rest != "" {
+		var part string
+		if i := strings.IndexAny(rest, pathSeparators); i >= 0 {
+			part, rest = rest[:i], rest[i+1:]
+		} else {
+			part, rest = rest, ""
+		}
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				if !clamp {
+					return "", escape
+				}
+				continue
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		cur := filepath.Join(root, filepath.Join(resolved...), part)
+		fi, err := os.Lstat(cur)
+		if err != nil {
+			if os.IsNotExist(err) {
+				resolved = append(resolved, part)
+				continue
+			}
+			return "", err
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			resolved = append(resolved, part)
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", &fs.PathError{Op: "resolve", Path: name, Err: errSymlinkLoop}
+		}
+		target, err := os.Readlink(cur)
+		if err != nil {
+			return "", err
+		}
+		if isAbsPath(target) {
+			if !clamp {
+				return "", escape
+			}
+			resolved = resolved[:0]
+			target = target[len(filepath.VolumeName(target)):]
+		}
+		rest = target + string(filepath.Separator) + rest
+	}
+	return filepath.Join(root, filepath.Join(resolved...)), nil
+}
+
+// isAbsPath reports whether p is absolute or, on Windows, rooted at the
+// current drive.
+func isAbsPath(p string) bool {
+	return filepath.IsAbs(p) || filepath.VolumeName(p) != "" || strings.HasPrefix(p, string(filepath.Separator))
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package file
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// noOpenat2 is set once openat2 turns out to be unavailable.
+var noOpenat2 atomic.Bool
+
+func openInRoot(root, name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if noOpenat2.Load() {
+		return openInRootFallback(root, name, flag, perm)
+	}
+	dir, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: root, Err: err}
+	}
+	defer unix.Close(dir)
+
+	how := unix.OpenHow{
+		Flags:   uint64(flag | unix.O_CLOEXEC),
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_MAGICLINKS,
+	}
+	if flag&os.O_CREATE != 0 {
+		how.Mode = uint64(perm.Perm())
+	}
+	for {
+		fd, err := unix.Openat2(dir, name, &how)
+		switch err {
+		case nil:
+			return os.NewFile(uintptr(fd), filepath.Join(root, name)), nil
+		case unix.EINTR, unix.EAGAIN:
+			// EAGAIN means a concurrent rename raced with the lookup.
+			continue
+		case unix.ENOSYS:
+			// Kernels before 5.6.
+			noOpenat2.Store(true)
+			return openInRootFallback(root, name, flag, perm)
+		case unix.EPERM:
+			// Possibly a seccomp filter rejecting openat2; the fallback
+			// reports the error again if it was genuine.
+			return openInRootFallback(root, name, flag, perm)
+		case unix.EXDEV:
+			err = errPathEscapes
+		}
+		return nil, &fs.PathError{Op: "openat2", Path: name, Err: err}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package file
+
+import (
+	"io/fs"
+	"os"
+)
+
+func openInRoot(root, name string, flag int, perm fs.FileMode) (*os.File, error) {
+	return openInRootFallback(root, name, flag, perm)
+}
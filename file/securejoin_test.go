@@ -0,0 +1,116 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSecureJoin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need extra privileges on windows")
+	}
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"dir/file": "x"})
+	for link, target := range map[string]string{
+		"dir/abs":   "/dir/file",
+		"dir/up":    "../../../..",
+		"dir/rel":   "file",
+		"dir/loop1": "loop2",
+		"dir/loop2": "loop1",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "dir/file", want: "dir/file"},
+		{path: "../../etc/passwd", want: "etc/passwd"},
+		{path: "/etc/passwd", want: "etc/passwd"},
+		{path: "dir/abs", want: "dir/file"},
+		{path: "dir/up/etc", want: "etc"},
+		{path: "dir/rel", want: "dir/file"},
+		{path: "dir/missing/../file", want: "dir/file"},
+		{path: "", want: ""},
+	}
+	for _, test := range tests {
+		got, err := SecureJoin(root, test.path)
+		if err != nil {
+			t.Errorf("SecureJoin(%q) error = %v", test.path, err)
+			continue
+		}
+		if want := filepath.Join(root, test.want); got != want {
+			t.Errorf("SecureJoin(%q) = %s, want %s", test.path, got, want)
+		}
+	}
+	if _, err := SecureJoin(root, "dir/loop1"); !errors.Is(err, errSymlinkLoop) {
+		t.Errorf("SecureJoin(loop) error = %v, want %v", err, errSymlinkLoop)
+	}
+}
+
+func TestOpenInRoot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need extra privileges on windows")
+	}
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"dir/file": "x"})
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"dir/rel": "file",
+		"dir/abs": outside,
+		"dir/up":  "../../" + filepath.Base(filepath.Dir(outside)),
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, open := range []struct {
+		name string
+		fn   func(root, name string, flag int, perm os.FileMode) (*os.File, error)
+	}{
+		{"OpenFileInRoot", OpenFileInRoot},
+		{"fallback", openInRootFallback},
+	} {
+		for _, name := range []string{"dir/file", "dir/rel", "./dir/../dir/file"} {
+			f, err := open.fn(root, name, os.O_RDONLY, 0)
+			if err != nil {
+				t.Errorf("%s(%q) error = %v", open.name, name, err)
+				continue
+			}
+			b, _ := io.ReadAll(f)
+			f.Close()
+			if string(b) != "x" {
+				t.Errorf("%s(%q) read %q, want %q", open.name, name, b, "x")
+			}
+		}
+		for _, name := range []string{"../secret", outside, "dir/abs", "dir/up/secret"} {
+			if f, err := open.fn(root, name, os.O_RDONLY, 0); !errors.Is(err, errPathEscapes) {
+				t.Errorf("%s(%q) error = %v, want %v", open.name, name, err, errPathEscapes)
+				if f != nil {
+					f.Close()
+				}
+			}
+		}
+
+		f, err := open.fn(root, "dir/"+open.name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			t.Errorf("%s(create) error = %v", open.name, err)
+			continue
+		}
+		f.Close()
+		if !IsExist(filepath.Join(root, "dir", open.name)) {
+			t.Errorf("%s(create) did not create the file", open.name)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	errInvalidSlots = errors.New("semaphore needs at least one slot")
+)
+
+// Semaphore is a counting semaphore shared by all processes on a host. It
+// is backed by n slot files in a directory; holding the write lock on a slot
+// file takes that slot.
+type Semaphore struct {
+	dir string
+	n   int
+}
+
+// NewSemaphore returns a semaphore allowing at most n holders at once,
+// creating dir if needed. Every process must use the same dir and n.
+func NewSemaphore(dir string, n int) (*Semaphore, error) {
+	if n < 1 {
+		return nil, &fs.PathError{Op: "NewSemaphore", Path: dir, Err: errInvalidSlots}
+	}
+	if err := os.MkdirAll(dir, DefaultFileMode); err != nil {
+		return nil, err
+	}
+	return &Semaphore{dir: dir, n: n}, nil
+}
+
+// SemaphoreSlot is a slot taken from a Semaphore.
+type SemaphoreSlot struct {
+	f     *os.File
+	index int
+}
+
+// Index returns the number of the slot, in the range [0, n).
+func (s *SemaphoreSlot) Index() int {
+	return s.index
+}
+
+// Release gives the slot back to the semaphore.
+func (s *SemaphoreSlot) Release() error {
+	return closeLocked(s.f)
+}
+
+// TryAcquire takes the lowest free slot without blocking. If every slot is
+// taken, the returned error satisfies IsAlreadyLocked.
+func (s *Semaphore) TryAcquire() (*SemaphoreSlot, error) {
+	for i := 0; i < s.n; i++ {
+		f, err := os.OpenFile(s.slotPath(i), os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err = Lock(f, true); err == nil {
+			return &SemaphoreSlot{f: f, index: i}, nil
+		}
+		f.Close()
+		if !IsAlreadyLocked(err) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "Acquire", Path: s.dir, Err: errAlreadyLocked}
+}
+
+// Acquire takes the lowest free slot, retrying with backoff until one is
+// free or ctx is done. If ctx ends first, the returned error wraps ctx.Err()
+// and IsAlreadyLocked reports true for it.
+func (s *Semaphore) Acquire(ctx context.Context) (*SemaphoreSlot, error) {
+	var slot *SemaphoreSlot
+	err := retryLocked(ctx, "Acquire", s.dir, func() (err error) {
+		slot, err = s.TryAcquire()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slot, nil
+}
+
+func (s *Semaphore) slotPath(i int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("slot-%d.lock", i))
+}
@@ -0,0 +1,55 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	sem, err := NewSemaphore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s0, err := sem.TryAcquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, err := sem.TryAcquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s0.Index() != 0 || s1.Index() != 1 {
+		t.Errorf("slots = %d, %d, want 0, 1", s0.Index(), s1.Index())
+	}
+	if _, err = sem.TryAcquire(); !IsAlreadyLocked(err) {
+		t.Errorf("TryAcquire() with all slots taken = %v, want already locked", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = sem.Acquire(ctx); !IsAlreadyLocked(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() = %v, want already locked and deadline exceeded", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { _ = s0.Release() })
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s2, err := sem.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2.Index() != 0 {
+		t.Errorf("Acquire() took slot %d, want 0", s2.Index())
+	}
+	if err = s1.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err = s2.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewSemaphore(t.TempDir(), 0); err == nil {
+		t.Errorf("NewSemaphore(0) should fail")
+	}
+}
@@ -0,0 +1,77 @@
+package file
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Preallocate reserves disk space for the first size bytes of f, growing
+// the file if it is shorter. It never shrinks the file. On Linux it uses
+// fallocate; where that is not supported the new part of the file is
+// written with zeros instead.
+func Preallocate(f *os.File, size int64) error {
+	if err := preallocate(f, size); err != nil {
+		return &fs.PathError{Op: "preallocate", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// PunchHole deallocates the given range of f, which then reads as zeros,
+// without changing the file size. It is only supported on Linux, by file
+// systems that support holes.
+func PunchHole(f *os.File, offset, length int64) error {
+	if err := punchHole(f, offset, length); err != nil {
+		return &fs.PathError{Op: "punchhole", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// SparseRegions calls fn with the offset and length of each region of f
+// holding data, in order, skipping the holes. Where holes cannot be
+// detected, the whole file is reported as one region. The file offset is
+// restored afterwards.
+func SparseRegions(f *os.File, fn func(offset, length int64) error) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return sparseRegions(f, fi.Size(), fn)
+}
+
+// zeroFill extends f to size by writing zeros, as a portable way to
+// allocate the space.
+func zeroFill(f *os.File, size int64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() >= size {
+		return nil
+	}
+	zeros := make([]byte, min(size-fi.Size(), 1<<20))
+	for off := fi.Size(); off < size; {
+		n, err := f.WriteAt(zeros[:min(int64(len(zeros)), size-off)], off)
+		if err != nil {
+			return err
+		}
+		off += int64(n)
+	}
+	return nil
+}
+
+// copySparse copies the data regions of src to dst, leaving the holes
+// unallocated, and returns the size of src.
+func copySparse(dst, src *os.File, size int64) (int64, error) {
+	if err := dst.Truncate(size); err != nil {
+		return 0, err
+	}
+	err := sparseRegions(src, size, func(offset, length int64) error {
+		_, err := io.Copy(io.NewOffsetWriter(dst, offset), io.NewSectionReader(src, offset, length))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
@@ -0,0 +1,68 @@
+//go:build linux
+
+package file
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func preallocate(f *os.File, size int64) error {
+	err := fallocate(f, 0, 0, size)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		return zeroFill(f, size)
+	}
+	return err
+}
+
+func punchHole(f *os.File, offset, length int64) error {
+	return fallocate(f, unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, offset, length)
+}
+
+func fallocate(f *os.File, mode uint32, offset, length int64) error {
+	for {
+		err := unix.Fallocate(int(f.Fd()), mode, offset, length)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
+
+func sparseRegions(f *os.File, size int64, fn func(offset, length int64) error) error {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	defer f.Seek(pos, io.SeekStart)
+
+	for offset := int64(0); offset < size; {
+		data, err := f.Seek(offset, unix.SEEK_DATA)
+		if err != nil {
+			if errors.Is(err, unix.ENXIO) {
+				// Only a hole is left.
+				return nil
+			}
+			if errors.Is(err, unix.EINVAL) && offset == 0 {
+				// Holes are not supported; everything is data.
+				return fn(0, size)
+			}
+			return err
+		}
+		hole, err := f.Seek(data, unix.SEEK_HOLE)
+		if err != nil {
+			return err
+		}
+		hole = min(hole, size)
+		if data >= hole {
+			return nil
+		}
+		if err = fn(data, hole-data); err != nil {
+			return err
+		}
+		offset = hole
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package file
+
+import (
+	"errors"
+	"os"
+)
+
+func preallocate(f *os.File, size int64) error {
+	return zeroFill(f, size)
+}
+
+func punchHole(f *os.File, offset, length int64) error {
+	return errors.ErrUnsupported
+}
+
+func sparseRegions(f *os.File, size int64, fn func(offset, length int64) error) error {
+	if size == 0 {
+		return nil
+	}
+	return fn(0, size)
+}
@@ -0,0 +1,125 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const sparseTestSize = 4 << 20
+
+// writeSparseFile creates a file with 64 KiB of data at the start and at
+// 2 MiB, and holes everywhere else.
+func writeSparseFile(t *testing.T, path string) []byte {
+	t.Helper()
+	want := make([]byte, sparseTestSize)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = f.Truncate(sparseTestSize); err != nil {
+		t.Fatal(err)
+	}
+	chunk := bytes.Repeat([]byte("data"), 16<<10)
+	for _, off := range []int{0, 2 << 20} {
+		if _, err = f.WriteAt(chunk, int64(off)); err != nil {
+			t.Fatal(err)
+		}
+		copy(want[off:], chunk)
+	}
+	return want
+}
+
+func TestSparseRegions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sparse")
+	want := writeSparseFile(t, path)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var total int64
+	got := make([]byte, sparseTestSize)
+	err = SparseRegions(f, func(offset, length int64) error {
+		total += length
+		_, err := f.ReadAt(got[offset:offset+length], offset)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("data regions miss some data")
+	}
+	if total == sparseTestSize {
+		t.Skip("file system does not report holes")
+	}
+	if total >= 1<<20 {
+		t.Errorf("data regions cover %d bytes, want far less than %d", total, sparseTestSize)
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	n, err := CopyFile(path, dst, CopyFileOptions{Sparse: true})
+	if err != nil || n != sparseTestSize {
+		t.Fatalf("CopyFile(Sparse) = %d, %v; want %d", n, err, sparseTestSize)
+	}
+	if b, err := os.ReadFile(dst); err != nil || !bytes.Equal(b, want) {
+		t.Errorf("sparse copy differs from the source: %v", err)
+	}
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, allocated, ok := fileBlocks(fi); ok && allocated >= 1<<20 {
+		t.Errorf("sparse copy allocates %d bytes, want far less than %d", allocated, sparseTestSize)
+	}
+}
+
+func TestPreallocate(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "segment"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = Preallocate(f, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.Size() != 1<<20 {
+		t.Fatalf("size after Preallocate = %d, %v; want %d", fi.Size(), err, 1<<20)
+	}
+	if _, _, allocated, ok := fileBlocks(fi); ok && allocated < 1<<20 {
+		t.Errorf("Preallocate allocated %d bytes, want %d", allocated, 1<<20)
+	}
+	if err = Preallocate(f, 10); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ = f.Stat(); fi.Size() != 1<<20 {
+		t.Errorf("Preallocate shrank the file to %d bytes", fi.Size())
+	}
+
+	if _, err = f.WriteAt(bytes.Repeat([]byte{1}, 1<<20), 0); err != nil {
+		t.Fatal(err)
+	}
+	err = PunchHole(f, 64<<10, 128<<10)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1<<20)
+	if _, err = f.ReadAt(b, 0); err != nil {
+		t.Fatal(err)
+	}
+	if b[64<<10-1] != 1 || b[64<<10] != 0 || b[192<<10-1] != 0 || b[192<<10] != 1 {
+		t.Errorf("PunchHole did not zero exactly the range")
+	}
+	if fi, _ = f.Stat(); fi.Size() != 1<<20 {
+		t.Errorf("PunchHole changed the size to %d", fi.Size())
+	}
+}
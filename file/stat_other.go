@@ -0,0 +1,20 @@
+//go:build !unix
+
+package file
+
+import "io/fs"
+
+// fileOwner reports ok == false because ownership is not exposed as
+// numeric ids on this platform.
+func fileOwner(fi fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// fileID identifies a file across its hard links.
+type fileID struct{}
+
+// fileBlocks reports ok == false because link counts and allocated blocks
+// are not exposed on this platform.
+func fileBlocks(fi fs.FileInfo) (id fileID, links uint64, allocated int64, ok bool) {
+	return fileID{}, 0, 0, false
+}
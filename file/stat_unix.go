@@ -0,0 +1,31 @@
+//go:build unix
+
+package file
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner returns the numeric owner and group of fi.
+func fileOwner(fi fs.FileInfo) (uid, gid int, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// fileID identifies a file across its hard links.
+type fileID struct {
+	dev, ino uint64
+}
+
+// fileBlocks returns the identity, link count and allocated size of fi.
+func fileBlocks(fi fs.FileInfo) (id fileID, links uint64, allocated int64, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, 0, 0, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink), int64(st.Blocks) * 512, true
+}
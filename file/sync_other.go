@@ -0,0 +1,9 @@
+//go:build !unix
+
+package file
+
+// syncDir is a no-op on platforms where a directory cannot be opened
+// and synced like a regular file.
+func syncDir(dir string) error {
+	return nil
+}
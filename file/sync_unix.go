@@ -0,0 +1,15 @@
+//go:build unix
+
+package file
+
+import "os"
+
+// syncDir flushes the directory entry changes (create, rename) of dir to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,21 @@
+//go:build darwin || freebsd
+
+package file
+
+import "golang.org/x/sys/unix"
+
+func usage(path string) (DiskUsage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return DiskUsage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	avail := uint64(max(int64(st.Bavail), 0))
+	return DiskUsage{
+		Total:      uint64(st.Blocks) * bsize,
+		Free:       uint64(st.Bfree) * bsize,
+		Available:  avail * bsize,
+		Inodes:     uint64(st.Files),
+		InodesFree: uint64(max(int64(st.Ffree), 0)),
+	}, nil
+}
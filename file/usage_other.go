@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package file
+
+import "errors"
+
+func usage(path string) (DiskUsage, error) {
+	return DiskUsage{}, errors.ErrUnsupported
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package file
+
+import "golang.org/x/sys/windows"
+
+func usage(path string) (DiskUsage, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	var u DiskUsage
+	if err = windows.GetDiskFreeSpaceEx(p, &u.Available, &u.Total, &u.Free); err != nil {
+		return DiskUsage{}, err
+	}
+	return u, nil
+}
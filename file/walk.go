@@ -0,0 +1,235 @@
+package file
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nexuer/utils/sets"
+)
+
+// WalkOptions configures Walk. The zero value walks the whole tree
+// sequentially, without ignore patterns and without following links.
+type WalkOptions struct {
+	// IgnoreFiles lists the names of per-directory pattern files, such as
+	// ".gitignore". Patterns in a file apply to the directory holding it and
+	// everything below it.
+	IgnoreFiles []string
+
+	// Ignore holds extra patterns, relative to the root, which are applied
+	// before those read from IgnoreFiles.
+	Ignore []string
+
+	// MaxDepth limits how deep Walk descends. Direct children of the root
+	// are at depth 1. Zero means no limit.
+	MaxDepth int
+
+	// FollowSymlinks descends into symbolic links to directories. A link
+	// that leads back to one of its own ancestors is reported but not
+	// descended into.
+	FollowSymlinks bool
+
+	// Parallel sets how many directories may be read at once. Values below
+	// two walk sequentially.
+	Parallel int
+}
+
+// WalkEntry is a file or directory found by Walk.
+type WalkEntry struct {
+	// Path is the root joined with Rel, using the OS path separator.
+	Path string
+
+	// Rel is the path relative to the root, separated by forward slashes.
+	Rel string
+
+	// Depth is the number of path elements in Rel.
+	Depth int
+
+	// Entry describes the file. When symbolic links are followed, it
+	// describes the link target.
+	Entry fs.DirEntry
+}
+
+// Walk returns the files and directories below root, excluding root itself
+// and anything matched by the ignore patterns. The result is in the same
+// deterministic order as filepath.WalkDir, regardless of Parallel.
+//
+// Walk does not stop at unreadable directories; it keeps going and returns
+// the entries it could read along with the joined errors.
+func Walk(root string, opts ...WalkOptions) ([]WalkEntry, error) {
+	w := &walker{
+		root: filepath.Clean(root),
+		opts: optional(opts),
+	}
+	w.workers = newWorkers(w.opts.Parallel)
+	var ancestors []string
+	if w.opts.FollowSymlinks {
+		real, err := filepath.EvalSymlinks(w.root)
+		if err != nil {
+			return nil, err
+		}
+		ancestors = []string{real}
+	}
+	w.walkDir(w.root, ".", 0, NewIgnoreMatcher(w.opts.Ignore...), ancestors)
+	err := w.wait()
+
+	sort.Slice(w.entries, func(i, j int) bool {
+		return comparePath(w.entries[i].Rel, w.entries[j].Rel) < 0
+	})
+	return w.entries, err
+}
+
+type walker struct {
+	*workers
+	root string
+	opts WalkOptions
+
+	mu      sync.Mutex
+	entries []WalkEntry
+}
+
+func (w *walker) walkDir(dir, rel string, depth int, m *IgnoreMatcher, ancestors []string) {
+	for _, name := range w.opts.IgnoreFiles {
+		var lines []string
+		err := ReadLineFunc(filepath.Join(dir, name), func(num int, line string) error {
+			lines = append(lines, strings.Clone(line))
+			return nil
+		})
+		if err == nil {
+			m = m.with(rel, lines)
+		} else if !os.IsNotExist(err) {
+			w.fail(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	found := make([]WalkEntry, 0, len(entries))
+	for _, d := range entries {
+		p := filepath.Join(dir, d.Name())
+		r := path.Join(rel, d.Name())
+		below := ancestors
+		if w.opts.FollowSymlinks {
+			if d.Type()&fs.ModeSymlink != 0 {
+				fi, err := os.Stat(p)
+				if err != nil {
+					w.fail(err)
+					continue
+				}
+				d = fs.FileInfoToDirEntry(fi)
+				if d.IsDir() {
+					if below, err = w.enter(p, ancestors); err != nil {
+						w.fail(err)
+						continue
+					}
+				}
+			} else if d.IsDir() {
+				parent := ancestors[len(ancestors)-1]
+				below = append(ancestors[:len(ancestors):len(ancestors)], filepath.Join(parent, d.Name()))
+			}
+		}
+		if m.match(r, d.IsDir()) {
+			continue
+		}
+		found = append(found, WalkEntry{Path: p, Rel: r, Depth: depth + 1, Entry: d})
+		if !d.IsDir() || w.opts.FollowSymlinks && below == nil {
+			continue
+		}
+		if w.opts.MaxDepth == 0 || depth+1 < w.opts.MaxDepth {
+			w.do(func() { w.walkDir(p, r, depth+1, m, below) })
+		}
+	}
+
+	w.mu.Lock()
+	w.entries = append(w.entries, found...)
+	w.mu.Unlock()
+}
+
+// enter resolves the directory link p and returns the ancestor chain to use
+// below it, or nil if it leads back to one of its ancestors.
+func (w *walker) enter(p string, ancestors []string) ([]string, error) {
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range ancestors {
+		if a == real {
+			return nil, nil
+		}
+	}
+	return append(ancestors[:len(ancestors):len(ancestors)], real), nil
+}
+
+// workers runs the directory reads of a tree walk on up to a fixed number of
+// goroutines and collects the errors they report.
+type workers struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// newWorkers returns workers that run up to parallel functions at once,
+// counting the caller's own goroutine.
+func newWorkers(parallel int) *workers {
+	ws := &workers{}
+	if parallel > 1 {
+		ws.sem = make(chan struct{}, parallel-1)
+	}
+	return ws
+}
+
+// do calls fn in a new goroutine if a worker is free, and otherwise in the
+// calling one, so that a full pool never blocks the walk.
+func (ws *workers) do(fn func()) {
+	if ws.sem != nil {
+		select {
+		case ws.sem <- struct{}{}:
+			ws.wg.Add(1)
+			go func() {
+				defer ws.wg.Done()
+				defer func() { <-ws.sem }()
+				fn()
+			}()
+			return
+		default:
+		}
+	}
+	fn()
+}
+
+func (ws *workers) fail(err error) {
+	ws.mu.Lock()
+	ws.errs = append(ws.errs, err)
+	ws.mu.Unlock()
+}
+
+// wait waits for the running functions and returns the joined errors.
+func (ws *workers) wait() error {
+	ws.wg.Wait()
+	return sets.NewErrors(ws.errs)
+}
+
+// comparePath orders slash-separated paths element by element, so that a
+// directory's contents sort directly after the directory itself.
+func comparePath(a, b string) int {
+	for {
+		ea, ra, _ := strings.Cut(a, "/")
+		eb, rb, _ := strings.Cut(b, "/")
+		if ea != eb {
+			return strings.Compare(ea, eb)
+		}
+		if ra == "" || rb == "" {
+			return strings.Compare(ra, rb)
+		}
+		a, b = ra, rb
+	}
+}
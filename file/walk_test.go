@@ -0,0 +1,79 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.log\nbuild/\n/vendor\n",
+		"a.go":             "",
+		"a.log":            "",
+		"build/out":        "",
+		"vendor/x.go":      "",
+		"pkg/vendor/y.go":  "",
+		"pkg/.gitignore":   "!keep.log\n*.tmp\n",
+		"pkg/keep.log":     "",
+		"pkg/drop.log":     "",
+		"pkg/z.tmp":        "",
+		"pkg/sub/deep.go":  "",
+		"pkg-other/b.go":   "",
+		"zz/sub/sub/c.txt": "",
+	})
+	if err := os.Symlink("..", filepath.Join(root, "pkg", "sub", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		".gitignore",
+		"a.go",
+		"pkg",
+		"pkg/.gitignore",
+		"pkg/keep.log",
+		"pkg/sub",
+		"pkg/sub/deep.go",
+		"pkg/sub/loop",
+		"pkg/vendor",
+		"pkg/vendor/y.go",
+		"pkg-other",
+		"pkg-other/b.go",
+		"zz",
+		"zz/sub",
+		"zz/sub/sub",
+		"zz/sub/sub/c.txt",
+	}
+	for _, parallel := range []int{0, 4} {
+		entries, err := Walk(root, WalkOptions{
+			IgnoreFiles:    []string{".gitignore"},
+			FollowSymlinks: true,
+			Parallel:       parallel,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, len(entries))
+		for i, e := range entries {
+			got[i] = e.Rel
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Walk(Parallel: %d) =\n%q\nwant\n%q", parallel, got, want)
+		}
+	}
+
+	entries, err := Walk(root, WalkOptions{MaxDepth: 1, Ignore: []string{".*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Depth != 1 {
+			t.Errorf("Walk(MaxDepth: 1) returned %s at depth %d", e.Rel, e.Depth)
+		}
+	}
+	if len(entries) != 7 {
+		t.Errorf("Walk(MaxDepth: 1) returned %d entries, want 7", len(entries))
+	}
+}
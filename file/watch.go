@@ -0,0 +1,353 @@
+package file
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errWatchLimit    = errors.New("watch limit reached")
+	errEventOverflow = errors.New("event queue overflowed, events were lost")
+)
+
+const defaultPollInterval = time.Second
+
+// Op describes a set of file operations reported by a Watcher.
+type Op uint32
+
+const (
+	OpCreate Op = 1 << iota
+	OpWrite
+	OpRemove
+	OpRename
+	OpChmod
+)
+
+// String returns the operations in op joined by '|', such as "CREATE|WRITE".
+func (op Op) String() string {
+	var names []string
+	for _, o := range []struct {
+		op   Op
+		name string
+	}{
+		{OpCreate, "CREATE"},
+		{OpWrite, "WRITE"},
+		{OpRemove, "REMOVE"},
+		{OpRename, "RENAME"},
+		{OpChmod, "CHMOD"},
+	} {
+		if op&o.op != 0 {
+			names = append(names, o.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Event is a change to a watched file or directory.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+// Has reports whether e includes the operation op.
+func (e Event) Has(op Op) bool {
+	return e.Op&op != 0
+}
+
+func (e Event) String() string {
+	return e.Op.String() + " " + e.Path
+}
+
+// WatchOptions configures Watch. The zero value watches only the given
+// paths and their direct children, delivering every event as it arrives.
+type WatchOptions struct {
+	// Recursive watches whole directory trees, including subdirectories
+	// created after the watch started.
+	Recursive bool
+
+	// Debounce delays each event until its path has been quiet for this
+	// long, merging the operations seen in the meantime into one event.
+	Debounce time.Duration
+
+	// Polling forces the stat-based polling backend.
+	Polling bool
+
+	// PollInterval is how often the polling backend checks for changes.
+	// It defaults to one second.
+	PollInterval time.Duration
+}
+
+// Watcher reports changes to files and directories. On Linux it uses
+// inotify; elsewhere, or when the inotify limits are exhausted, it polls.
+// If the limits are only hit for a directory created while watching, that
+// directory is polled on its own.
+type Watcher struct {
+	opts    WatchOptions
+	backend watchBackend
+	polling bool
+
+	raw    chan Event
+	events chan Event
+	errors chan error
+	done   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// watchBackend produces raw events through the emit and fail callbacks
+// given to it on creation until it is closed.
+type watchBackend interface {
+	close() error
+}
+
+// Watch starts watching the given files and directories. Watching a
+// directory reports changes to its entries; every path must exist.
+func Watch(paths []string, opts ...WatchOptions) (*Watcher, error) {
+	w := &Watcher{
+		opts:   optional(opts),
+		raw:    make(chan Event, 64),
+		events: make(chan Event, 64),
+		errors: make(chan error, 16),
+		done:   make(chan struct{}),
+	}
+	if w.opts.PollInterval <= 0 {
+		w.opts.PollInterval = defaultPollInterval
+	}
+	cleaned := make([]string, len(paths))
+	for i, p := range paths {
+		cleaned[i] = filepath.Clean(p)
+		if _, err := os.Stat(cleaned[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if !w.opts.Polling {
+		w.backend, err = newNativeBackend(cleaned, w.opts, w.emit, w.fail)
+	}
+	if w.opts.Polling || errors.Is(err, errors.ErrUnsupported) || errors.Is(err, errWatchLimit) {
+		w.polling = true
+		w.backend, err = newPollBackend(cleaned, w.opts, w.emit, w.fail)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	w.wg.Add(1)
+	go w.dispatch()
+	return w, nil
+}
+
+// Events returns the channel on which events are delivered. It is closed
+// by Close.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Errors returns the channel on which backend errors are delivered. Errors
+// are dropped while the channel is full.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Polling reports whether w uses the polling backend for all paths.
+func (w *Watcher) Polling() bool {
+	return w.polling
+}
+
+// Close stops watching and closes the Events channel.
+func (w *Watcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.backend.close()
+		w.wg.Wait()
+		close(w.events)
+	})
+	return err
+}
+
+func (w *Watcher) emit(e Event) {
+	select {
+	case w.raw <- e:
+	case <-w.done:
+	}
+}
+
+func (w *Watcher) fail(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
+
+func (w *Watcher) send(e Event) bool {
+	select {
+	case w.events <- e:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// dispatch forwards raw events to the Events channel, debouncing them per
+// path when configured to.
+func (w *Watcher) dispatch() {
+	defer w.wg.Done()
+	type pendingEvent struct {
+		op Op
+		at time.Time
+	}
+	pending := make(map[string]*pendingEvent)
+	var order []string
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	for {
+		select {
+		case e := <-w.raw:
+			if w.opts.Debounce <= 0 {
+				if !w.send(e) {
+					return
+				}
+				continue
+			}
+			p, ok := pending[e.Path]
+			if !ok {
+				p = &pendingEvent{}
+				pending[e.Path] = p
+				order = append(order, e.Path)
+			}
+			p.op |= e.Op
+			p.at = time.Now().Add(w.opts.Debounce)
+			if len(order) == 1 && !ok {
+				timer.Reset(w.opts.Debounce)
+			}
+		case now := <-timer.C:
+			next := time.Duration(0)
+			kept := order[:0]
+			for _, path := range order {
+				p := pending[path]
+				if wait := p.at.Sub(now); wait > 0 {
+					kept = append(kept, path)
+					if next == 0 || wait < next {
+						next = wait
+					}
+					continue
+				}
+				delete(pending, path)
+				if !w.send(Event{Path: path, Op: p.op}) {
+					return
+				}
+			}
+			order = kept
+			if next > 0 {
+				timer.Reset(next)
+			}
+		case <-w.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// pollBackend detects changes by comparing periodic snapshots of file
+// metadata. A rename shows up as a removal and a creation.
+type pollBackend struct {
+	paths     []string
+	recursive bool
+	emit      func(Event)
+	fail      func(error)
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+type pollState struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+func newPollBackend(paths []string, opts WatchOptions, emit func(Event), fail func(error)) (watchBackend, error) {
+	b := &pollBackend{
+		paths:     paths,
+		recursive: opts.Recursive,
+		emit:      emit,
+		fail:      fail,
+		done:      make(chan struct{}),
+	}
+	prev := b.snapshot()
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				prev = b.diff(prev, b.snapshot())
+			case <-b.done:
+				return
+			}
+		}
+	}()
+	return b, nil
+}
+
+func (b *pollBackend) snapshot() map[string]pollState {
+	states := make(map[string]pollState)
+	for _, root := range b.paths {
+		_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p != root && !os.IsNotExist(err) {
+					b.fail(err)
+				}
+				return nil
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			states[p] = pollState{size: fi.Size(), modTime: fi.ModTime(), mode: fi.Mode()}
+			if d.IsDir() && p != root && !b.recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+	return states
+}
+
+func (b *pollBackend) diff(prev, cur map[string]pollState) map[string]pollState {
+	for p, c := range cur {
+		old, ok := prev[p]
+		switch {
+		case !ok:
+			b.emit(Event{Path: p, Op: OpCreate})
+		case old.mode != c.mode:
+			b.emit(Event{Path: p, Op: OpChmod})
+		case old.size != c.size || !old.modTime.Equal(c.modTime):
+			if !c.mode.IsDir() {
+				b.emit(Event{Path: p, Op: OpWrite})
+			}
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			b.emit(Event{Path: p, Op: OpRemove})
+		}
+	}
+	return cur
+}
+
+func (b *pollBackend) close() error {
+	close(b.done)
+	b.wg.Wait()
+	return nil
+}
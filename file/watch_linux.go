@@ -0,0 +1,245 @@
+//go:build linux
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_DELETE | unix.IN_DELETE_SELF | unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO | unix.IN_MOVE_SELF
+
+// inotifyBackend reports events from an inotify instance. Its descriptor is
+// non-blocking, so closing the *os.File wakes up the pending Read.
+type inotifyBackend struct {
+	f            *os.File
+	fd           int
+	recursive    bool
+	pollInterval time.Duration
+	emit         func(Event)
+	fail         func(error)
+	wg           sync.WaitGroup
+
+	// watches maps watch descriptors to paths and roots holds the paths
+	// given to Watch. polled holds the subdirectories that are polled
+	// because the inotify limits were hit when they appeared. They are
+	// only touched by the read loop once the backend is running.
+	watches map[int]string
+	roots   map[string]bool
+	polled  map[string]watchBackend
+}
+
+func newNativeBackend(paths []string, opts WatchOptions, emit func(Event), fail func(error)) (watchBackend, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, inotifyError("inotify_init1", "", err)
+	}
+	b := &inotifyBackend{
+		f:            os.NewFile(uintptr(fd), "inotify"),
+		fd:           fd,
+		recursive:    opts.Recursive,
+		pollInterval: opts.PollInterval,
+		emit:         emit,
+		fail:         fail,
+		watches:      make(map[int]string),
+		roots:        make(map[string]bool),
+		polled:       make(map[string]watchBackend),
+	}
+	for _, p := range paths {
+		b.roots[p] = true
+		if err = b.add(p, false); err != nil {
+			b.f.Close()
+			return nil, err
+		}
+	}
+	b.wg.Add(1)
+	go b.read()
+	return b, nil
+}
+
+// add watches p, and every directory below it when recursive. If announce
+// is set, entries found below p are reported as created, since they may
+// have appeared before the watch was in place.
+//
+// announce is only set once the backend is running, when Watch can no
+// longer fall back to polling as a whole; a directory that cannot be
+// watched because of the inotify limits is then polled on its own.
+func (b *inotifyBackend) add(p string, announce bool) error {
+	wd, err := unix.InotifyAddWatch(b.fd, p, inotifyMask)
+	if err != nil {
+		err = inotifyError("inotify_add_watch", p, err)
+		if announce && errors.Is(err, errWatchLimit) {
+			return b.poll(p)
+		}
+		return err
+	}
+	b.watches[wd] = p
+	if !b.recursive {
+		return nil
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		// p is a file or has already gone away.
+		return nil
+	}
+	for _, d := range entries {
+		child := filepath.Join(p, d.Name())
+		if announce {
+			b.emit(Event{Path: child, Op: OpCreate})
+		}
+		if d.IsDir() {
+			if err = b.add(child, announce); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// poll watches the tree at p with a polling backend, reporting the entries
+// already in it as created.
+func (b *inotifyBackend) poll(p string) error {
+	pb, err := newPollBackend([]string{p}, WatchOptions{Recursive: true, PollInterval: b.pollInterval}, b.emit, b.fail)
+	if err != nil {
+		return err
+	}
+	b.polled[p] = pb
+	return filepath.WalkDir(p, func(q string, d fs.DirEntry, err error) error {
+		if err == nil && q != p {
+			b.emit(Event{Path: q, Op: OpCreate})
+		}
+		return nil
+	})
+}
+
+// forget stops watching the directory p and everything below it once it
+// has been removed or moved away, so that stale watches do not count
+// against the inotify limits.
+func (b *inotifyBackend) forget(p string) {
+	below := func(q string) bool {
+		return !b.roots[q] && (q == p || strings.HasPrefix(q, p+string(filepath.Separator)))
+	}
+	for wd, q := range b.watches {
+		if below(q) {
+			// The watch is already gone if p was removed.
+			_, _ = unix.InotifyRmWatch(b.fd, uint32(wd))
+			delete(b.watches, wd)
+		}
+	}
+	for q, pb := range b.polled {
+		if below(q) {
+			_ = pb.close()
+			delete(b.polled, q)
+		}
+	}
+}
+
+func (b *inotifyBackend) read() {
+	defer b.wg.Done()
+	var buf [unix.SizeofInotifyEvent * 4096]byte
+	for {
+		n, err := b.f.Read(buf[:])
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				b.fail(err)
+			}
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(raw.Len)]
+			offset += unix.SizeofInotifyEvent + int(raw.Len)
+			b.handle(raw, string(trimNUL(nameBytes)))
+		}
+	}
+}
+
+func (b *inotifyBackend) handle(raw *unix.InotifyEvent, name string) {
+	mask := raw.Mask
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		b.fail(errEventOverflow)
+		return
+	}
+	dir, ok := b.watches[int(raw.Wd)]
+	if !ok {
+		return
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		delete(b.watches, int(raw.Wd))
+		return
+	}
+	p := dir
+	if name != "" {
+		p = filepath.Join(dir, name)
+	}
+
+	var op Op
+	if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		op |= OpCreate
+	}
+	if mask&unix.IN_MODIFY != 0 {
+		op |= OpWrite
+	}
+	if mask&unix.IN_ATTRIB != 0 {
+		op |= OpChmod
+	}
+	if mask&(unix.IN_DELETE|unix.IN_DELETE_SELF) != 0 {
+		op |= OpRemove
+	}
+	if mask&(unix.IN_MOVED_FROM|unix.IN_MOVE_SELF) != 0 {
+		op |= OpRename
+	}
+	// Events on a subdirectory itself are already reported by the watch on
+	// its parent, so they are only reported for the watch roots.
+	if op == 0 || name == "" && !b.roots[dir] {
+		return
+	}
+	b.emit(Event{Path: p, Op: op})
+
+	if b.recursive && mask&unix.IN_ISDIR != 0 && mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0 {
+		b.forget(p)
+	}
+	if b.recursive && op&OpCreate != 0 && mask&unix.IN_ISDIR != 0 {
+		if err := b.add(p, true); err != nil {
+			b.fail(err)
+		}
+	}
+}
+
+func (b *inotifyBackend) close() error {
+	err := b.f.Close()
+	b.wg.Wait()
+	for _, pb := range b.polled {
+		_ = pb.close()
+	}
+	return err
+}
+
+func trimNUL(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
+
+// inotifyError wraps errors caused by exhausted inotify limits with
+// errWatchLimit so that Watch can fall back to polling.
+func inotifyError(op, path string, err error) error {
+	if err == unix.EMFILE || err == unix.ENFILE || err == unix.ENOSPC {
+		return fmt.Errorf("%w: %w", errWatchLimit, &os.PathError{Op: op, Path: path, Err: err})
+	}
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package file
+
+import "errors"
+
+// newNativeBackend reports that no native backend exists on this
+// platform, making Watch fall back to polling.
+func newNativeBackend(paths []string, opts WatchOptions, emit func(Event), fail func(error)) (watchBackend, error) {
+	return nil, errors.ErrUnsupported
+}
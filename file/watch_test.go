@@ -0,0 +1,78 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func waitEvent(t *testing.T, w *Watcher, path string, op Op) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-w.Events():
+			if e.Path == path && e.Has(op) {
+				return
+			}
+		case err := <-w.Errors():
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v on %s", op, path)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for _, polling := range []bool{false, true} {
+		dir := t.TempDir()
+		w, err := Watch([]string{dir}, WatchOptions{
+			Recursive:    true,
+			Polling:      polling,
+			PollInterval: 10 * time.Millisecond,
+			Debounce:     5 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !polling && w.Polling() && runtime.GOOS == "linux" {
+			t.Errorf("Watch() fell back to polling on Linux")
+		}
+
+		file := filepath.Join(dir, "config.yaml")
+		if err = os.WriteFile(file, []byte("a"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, file, OpCreate)
+
+		time.Sleep(20 * time.Millisecond)
+		if err = os.WriteFile(file, []byte("ab"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, file, OpWrite)
+
+		sub := filepath.Join(dir, "sub")
+		if err = os.Mkdir(sub, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, sub, OpCreate)
+		nested := filepath.Join(sub, "nested.txt")
+		if err = os.WriteFile(nested, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, nested, OpCreate)
+
+		if err = os.Remove(file); err != nil {
+			t.Fatal(err)
+		}
+		waitEvent(t, w, file, OpRemove)
+
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for range w.Events() {
+		}
+	}
+}